@@ -0,0 +1,96 @@
+package sjson
+
+import "unsafe"
+
+// appendSet appends the json with the operation performed to dst. The json
+// is appended as is when nothing changes, and dst is returned as is on
+// error.
+func appendSet(dst, json []byte, path string, p *Path, op *setOp,
+	opts *Options) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	op.dst = dst
+	op.appending = true
+	var res []byte
+	var err error
+	if p != nil {
+		res, err = setCompiled(jstr, p, op, opts)
+	} else {
+		res, err = set(jstr, path, op, opts)
+	}
+	if err == errNoChange {
+		return append(dst, json...), nil
+	}
+	if err != nil {
+		return dst, err
+	}
+	return res, nil
+}
+
+// AppendSet appends the json with a value set at the specified path to dst
+// and returns the extended buffer, in the same way as strconv.AppendInt.
+// Reusing dst avoids allocating a new slice for every call. The json is not
+// modified, and it must not overlap the spare capacity of dst.
+//
+//	buf = AppendSet(buf[:0], json, "name.last", "Smith")
+//
+// On error dst is returned unmodified.
+func AppendSet(dst, json []byte, path string, value interface{}) ([]byte,
+	error) {
+	return AppendSetOptions(dst, json, path, value, nil)
+}
+
+// AppendSetOptions appends the json with a value set at the specified path
+// to dst, with options. The ReplaceInPlace option is ignored.
+func AppendSetOptions(dst, json []byte, path string, value interface{},
+	opts *Options) ([]byte, error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return dst, err
+	}
+	return appendSet(dst, json, path, nil, &op, opts)
+}
+
+// AppendSetRaw appends the json with a raw json value set at the specified
+// path to dst.
+func AppendSetRaw(dst, json []byte, path string, value []byte) ([]byte,
+	error) {
+	return AppendSetRawOptions(dst, json, path, value, nil)
+}
+
+// AppendSetRawOptions appends the json with a raw json value set at the
+// specified path to dst, with options. The ReplaceInPlace option is ignored.
+func AppendSetRawOptions(dst, json []byte, path string, value []byte,
+	opts *Options) ([]byte, error) {
+	op := setOp{raw: *(*string)(unsafe.Pointer(&value))}
+	return appendSet(dst, json, path, nil, &op, opts)
+}
+
+// AppendDelete appends the json with the value at the specified path
+// deleted to dst.
+func AppendDelete(dst, json []byte, path string) ([]byte, error) {
+	return appendSet(dst, json, path, nil, &setOp{del: true}, nil)
+}
+
+// AppendSetPath appends the json with a value set at a compiled path to dst.
+func AppendSetPath(dst, json []byte, path *Path, value interface{}) ([]byte,
+	error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return dst, err
+	}
+	return appendSet(dst, json, "", path, &op, nil)
+}
+
+// AppendSetRawPath appends the json with a raw json value set at a compiled
+// path to dst.
+func AppendSetRawPath(dst, json []byte, path *Path, value []byte) ([]byte,
+	error) {
+	op := setOp{raw: *(*string)(unsafe.Pointer(&value))}
+	return appendSet(dst, json, "", path, &op, nil)
+}
+
+// AppendDeletePath appends the json with the value at a compiled path
+// deleted to dst.
+func AppendDeletePath(dst, json []byte, path *Path) ([]byte, error) {
+	return appendSet(dst, json, "", path, &setOp{del: true}, nil)
+}
@@ -0,0 +1,65 @@
+package sjson
+
+import (
+	"testing"
+)
+
+func TestAppendSet(t *testing.T) {
+	tests := []struct {
+		json, path string
+		value      interface{}
+		opts       *Options
+		expect     string
+	}{
+		{`{"a":1}`, "a", 2, nil, `{"a":2}`},
+		{`{"a":1}`, "b.c", "x", nil, `{"a":1,"b":{"c":"x"}}`},
+		{`{"a":1}`, "a", 2, &Options{Optimistic: true, ReplaceInPlace: true},
+			`{"a":2}`},
+		{`{"a":[1,2,1]}`, "a.#(==1)#", 3, nil, `{"a":[3,2,3]}`},
+		{`{"a":[1,2,1]}`, "a.#(==1)#", dtype{}, nil, `{"a":[2]}`},
+		{`{"a":[]}`, "a.#(id==1).b", 2, &Options{Upsert: true},
+			`{"a":[{"id":1,"b":2}]}`},
+		{`{"a":1}`, "/a", 2, &Options{Pointer: true}, `{"a":2}`},
+		{`{"a":1}`, "", 2, &Options{Pointer: true}, `2`},
+		{`{"a":1}`, "x", dtype{}, nil, `{"a":1}`},
+		{"{\n  \"a\": 1\n}", "b", 2, &Options{MatchIndent: true},
+			"{\n  \"a\": 1,\n  \"b\": 2\n}"},
+	}
+	for i, tt := range tests {
+		json := []byte(tt.json)
+		dst := []byte("    prefix ")
+		res, err := AppendSetOptions(dst, json, tt.path, tt.value, tt.opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if string(res) != "    prefix "+tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%s'", i, tt.expect, res)
+		}
+		if string(json) != tt.json {
+			t.Fatalf("test %d: json was modified '%s'", i, json)
+		}
+	}
+	dst := []byte("x")
+	res, err := AppendSet(dst, []byte(`{"a":[]}`), "a.b", 1)
+	if err == nil || string(res) != "x" {
+		t.Fatalf("expected an error, got '%s', %v", res, err)
+	}
+}
+
+func TestAppendSetAllocs(t *testing.T) {
+	json := []byte(`{"widget":{"window":{"name":"main_window"}}}`)
+	value := []byte(`"other_window"`)
+	p, _ := CompilePath("widget.window.name")
+	buf := make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = AppendSetRawPath(buf[:0], json, p, value)
+		buf, _ = AppendSetRaw(buf[:0], json, "widget.window.name", value)
+		buf, _ = AppendDeletePath(buf[:0], json, p)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+	if string(buf) != `{"widget":{"window":{}}}` {
+		t.Fatalf("unexpected result '%s'", buf)
+	}
+}
@@ -0,0 +1,219 @@
+package sjson
+
+import (
+	"sort"
+	"strconv"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// OpKind is the kind of operation performed by an Op.
+type OpKind int
+
+const (
+	// OpSet sets Value at Path, like Set.
+	OpSet OpKind = iota
+	// OpSetRaw sets the raw json in Value at Path, like SetRaw. The Value
+	// must be a string or a []byte.
+	OpSetRaw
+	// OpDelete deletes the value at Path, like Delete. The Value is ignored.
+	OpDelete
+)
+
+// Op is a single operation for SetMany.
+type Op struct {
+	Kind  OpKind
+	Path  string
+	Value interface{}
+}
+
+// edit is a resolved operation that replaces jstr[start:end] with the
+// value of op.
+type edit struct {
+	start, end int
+	op         setOp
+}
+
+// opValue returns the set operation for an Op.
+func opValue(op Op) (setOp, error) {
+	switch op.Kind {
+	case OpDelete:
+		return setOp{del: true}, nil
+	case OpSetRaw:
+		switch v := op.Value.(type) {
+		case string:
+			return setOp{raw: v}, nil
+		case []byte:
+			return setOp{raw: *(*string)(unsafe.Pointer(&v))}, nil
+		}
+		return setOp{}, &PathError{Path: op.Path, Component: -1, Offset: -1,
+			Err: ErrInvalidValue}
+	default:
+		return rawValue(op.Value)
+	}
+}
+
+// parseSimplePath splits a path into its components. The ok return value is
+// false when the path is not a simple path.
+func parseSimplePath(path string) (paths []pathResult, ok bool) {
+	r, simple := parsePath(path)
+	if !simple {
+		return nil, false
+	}
+	paths = append(paths, r)
+	for r.more {
+		r, simple = parsePath(r.path)
+		if !simple {
+			return nil, false
+		}
+		paths = append(paths, r)
+	}
+	return paths, true
+}
+
+// locatePaths finds the value for the paths in jstr in the same way that
+// appendRawPaths does, returning its index and length.
+func locatePaths(jstr string, paths []pathResult, del bool) (index, n int,
+	ok bool) {
+	for i := 0; i < len(paths); i++ {
+		var res gjson.Result
+		var found bool
+		if del && i == len(paths)-1 && paths[i].part == "-1" &&
+			!paths[i].force {
+			res = gjson.Get(jstr, "#")
+			if res.Int() > 0 {
+				res = gjson.Get(jstr,
+					strconv.FormatInt(int64(res.Int()-1), 10))
+				found = true
+			}
+		}
+		if !found {
+			res = gjson.Get(jstr, paths[i].gpart)
+		}
+		if res.Index <= 0 {
+			return 0, 0, false
+		}
+		index += res.Index
+		jstr = res.Raw
+	}
+	return index, len(jstr), true
+}
+
+// hasArrayPart returns true if any of the path components may refer to an
+// array element.
+func hasArrayPart(paths []pathResult) bool {
+	for _, p := range paths {
+		if _, numeric := atoui(p); numeric || (!p.force && p.part == "-1") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMany applies multiple set and delete operations to the json in order.
+// The result is the same as calling Set, SetRaw or Delete for each
+// operation in turn, except that all operations on values that already exist
+// in the document are performed in a single pass over the json.
+// Operations that create new values, or that depend on the outcome of a
+// previous operation, are applied one at a time.
+func SetMany(json string, ops []Op) (string, error) {
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, err := SetManyBytes(jsonb, ops)
+	return string(res), err
+}
+
+// SetManyBytes applies multiple set and delete operations to the json.
+// If working with bytes, this method preferred over
+// SetMany(string(data), ops)
+func SetManyBytes(json []byte, ops []Op) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	edits := make([]edit, 0, len(ops))
+	var deleted bool
+	var i int
+	for ; i < len(ops); i++ {
+		op, err := opValue(ops[i])
+		if err != nil {
+			return nil, err
+		}
+		if ops[i].Path == "" {
+			return nil, &PathError{Component: -1, Offset: -1,
+				Err: ErrEmptyPath}
+		}
+		paths, ok := parseSimplePath(ops[i].Path)
+		if !ok || (deleted && hasArrayPart(paths)) {
+			// a complex path, or an array element that may have shifted
+			// by a previous delete.
+			break
+		}
+		index, n, ok := locatePaths(jstr, paths, op.del)
+		if !ok {
+			break
+		}
+		e := edit{start: index, end: index + n, op: op}
+		if op.del {
+			e.start, e.end = deleteSpan(jstr, index, n)
+			deleted = true
+		}
+		if overlaps(edits, e) {
+			break
+		}
+		edits = append(edits, e)
+	}
+	var out []byte
+	if len(edits) == 0 {
+		out = json
+	} else {
+		out = applyEdits(jstr, edits)
+	}
+	for ; i < len(ops); i++ {
+		op, err := opValue(ops[i])
+		if err != nil {
+			return nil, err
+		}
+		jstr = *(*string)(unsafe.Pointer(&out))
+		res, err := set(jstr, ops[i].Path, &op, nil)
+		if err != nil {
+			if err == errNoChange {
+				continue
+			}
+			return nil, err
+		}
+		out = res
+	}
+	return out, nil
+}
+
+// overlaps returns true if e overlaps any of the edits.
+func overlaps(edits []edit, e edit) bool {
+	for _, x := range edits {
+		if e.start < x.end && x.start < e.end {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEdits writes jstr with all non-overlapping edits applied.
+func applyEdits(jstr string, edits []edit) []byte {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	sz := len(jstr)
+	for _, e := range edits {
+		sz += len(e.op.raw) - (e.end - e.start)
+		if e.op.stringify {
+			sz += 2
+		}
+	}
+	buf := make([]byte, 0, sz)
+	var pos int
+	for _, e := range edits {
+		buf = append(buf, jstr[pos:e.start]...)
+		buf = e.op.appendValue(buf)
+		pos = e.end
+	}
+	return append(buf, jstr[pos:]...)
+}
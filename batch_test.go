@@ -0,0 +1,89 @@
+package sjson
+
+import "testing"
+
+func setSequential(t *testing.T, json string, ops []Op) string {
+	t.Helper()
+	var err error
+	for _, op := range ops {
+		switch op.Kind {
+		case OpSet:
+			json, err = Set(json, op.Path, op.Value)
+		case OpSetRaw:
+			json, err = SetRaw(json, op.Path, op.Value.(string))
+		case OpDelete:
+			json, err = Delete(json, op.Path)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return json
+}
+
+func TestSetMany(t *testing.T) {
+	tests := [][]Op{
+		{
+			{Kind: OpSet, Path: "name.last", Value: "Smith"},
+			{Kind: OpSet, Path: "age", Value: 38},
+			{Kind: OpSetRaw, Path: "children.1", Value: `{"a":1}`},
+			{Kind: OpDelete, Path: "fav\\.movie"},
+		},
+		{
+			{Kind: OpDelete, Path: "children.0"},
+			{Kind: OpDelete, Path: "children.0"},
+			{Kind: OpSet, Path: "children.0", Value: "Bob"},
+		},
+		{
+			{Kind: OpDelete, Path: "name.first"},
+			{Kind: OpDelete, Path: "name.last"},
+			{Kind: OpDelete, Path: "age"},
+		},
+		{
+			{Kind: OpSet, Path: "name.middle", Value: "J"},
+			{Kind: OpSet, Path: "name", Value: "Tom"},
+		},
+		{
+			{Kind: OpSet, Path: "name", Value: "Tom"},
+			{Kind: OpSet, Path: "name.first", Value: "Tim"},
+		},
+		{
+			{Kind: OpSet, Path: "friends.#(last=\"Murphy\")#.age", Value: 1},
+			{Kind: OpSet, Path: "new.path", Value: true},
+			{Kind: OpDelete, Path: "missing"},
+			{Kind: OpDelete, Path: "children.-1"},
+		},
+		{
+			{Kind: OpSet, Path: "friends.1.first", Value: "Rog\"er"},
+			{Kind: OpSet, Path: "friends.1.first", Value: "Roger"},
+		},
+	}
+	for i, ops := range tests {
+		expect := setSequential(t, example, ops)
+		json, err := SetMany(example, ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if json != expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, expect, json)
+		}
+		jsonb, err := SetManyBytes([]byte(example), ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(jsonb) != expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, expect, jsonb)
+		}
+	}
+}
+
+func TestSetManyErrors(t *testing.T) {
+	_, err := SetMany(`{}`, []Op{{Kind: OpSetRaw, Path: "a", Value: 1}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	_, err = SetMany(`{}`, []Op{{Kind: OpSet, Path: "", Value: 1}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,249 @@
+// Command sjson sets and deletes values in json documents from the command
+// line. Only the edited values change, the rest of the document is written
+// exactly as it was read.
+//
+// Usage:
+//
+//	sjson set [flags] [file] path value [path value ...]
+//	sjson delete [flags] [file] path [path ...]
+//
+// The document is read from file, or from stdin when no file is given or
+// the file is "-". A value that is valid json is set as is, so '"Anderson"'
+// and 'Anderson' both set a string, and '42' sets a number.
+//
+// For set, the file is the argument that's left over after the pairs of
+// paths and values. For delete, the first argument is the file only when it
+// is "-" or an existing file, and otherwise all arguments are paths.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const usage = `usage: sjson set [flags] [file] path value [path value ...]
+       sjson delete [flags] [file] path [path ...]
+
+Reads stdin when no file is given, or the file is "-". For delete, the
+first argument is only the file when it is "-" or an existing file.
+
+flags:
+  --raw        set values as raw json without checking them
+  --string     set values as strings, even if they are valid json
+  --delete     delete the paths, the same as the delete command
+  --in-place   write the result back to the file instead of stdout
+  --ndjson     edit each line of newline-delimited json
+  --filter     only edit lines that match a gjson condition, with --ndjson
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// config is the parsed command line.
+type config struct {
+	raw, str, del bool
+	inPlace       bool
+	ndjson        bool
+	filter        string
+	file          string
+	args          []string
+}
+
+// run runs the command and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cfg, err := parseArgs(args)
+	if err == flag.ErrHelp {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "sjson: %v\n%s", err, usage)
+		return 2
+	}
+	if err := edit(cfg, stdin, stdout); err != nil {
+		fmt.Fprintf(stderr, "sjson: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseArgs parses the command line. Flags may appear anywhere after the
+// command, and arguments after "--" are never flags.
+func parseArgs(args []string) (*config, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+	cfg := new(config)
+	switch args[0] {
+	case "set":
+	case "delete":
+		cfg.del = true
+	case "-h", "-help", "--help", "help":
+		return nil, flag.ErrHelp
+	default:
+		return nil, fmt.Errorf("unknown command %q", args[0])
+	}
+	fs := flag.NewFlagSet("sjson", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&cfg.raw, "raw", false, "")
+	fs.BoolVar(&cfg.str, "string", false, "")
+	fs.BoolVar(&cfg.del, "delete", cfg.del, "")
+	fs.BoolVar(&cfg.inPlace, "in-place", false, "")
+	fs.BoolVar(&cfg.ndjson, "ndjson", false, "")
+	fs.StringVar(&cfg.filter, "filter", "", "")
+	var flags, pos []string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			pos = append(pos, args[i+1:]...)
+			i = len(args)
+		case len(a) > 1 && a[0] == '-' && !isNumber(a):
+			flags = append(flags, a)
+			if name := strings.TrimLeft(a, "-"); name == "filter" &&
+				i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			}
+		default:
+			pos = append(pos, a)
+		}
+	}
+	if err := fs.Parse(flags); err != nil {
+		return nil, err
+	}
+	if cfg.raw && cfg.str {
+		return nil, fmt.Errorf("--raw and --string can't be used together")
+	}
+	if cfg.filter != "" && !cfg.ndjson {
+		return nil, fmt.Errorf("--filter requires --ndjson")
+	}
+	// the file is the argument that's left over after the paths and values,
+	// and for delete it must be "-" or a file that exists
+	if cfg.del {
+		if len(pos) > 1 && isFile(pos[0]) {
+			cfg.file, pos = pos[0], pos[1:]
+		}
+	} else if len(pos)%2 == 1 {
+		cfg.file, pos = pos[0], pos[1:]
+	}
+	if len(pos) == 0 {
+		return nil, fmt.Errorf("missing path")
+	}
+	if cfg.file == "-" {
+		cfg.file = ""
+	}
+	if cfg.inPlace && cfg.file == "" {
+		return nil, fmt.Errorf("--in-place requires a file")
+	}
+	cfg.args = pos
+	return cfg, nil
+}
+
+// isFile returns true if the argument is "-" or names an existing file.
+func isFile(arg string) bool {
+	if arg == "-" {
+		return true
+	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
+}
+
+// isNumber returns true for arguments such as -1, which are values rather
+// than flags.
+func isNumber(s string) bool {
+	return gjson.Parse(s).Type == gjson.Number && gjson.Valid(s)
+}
+
+// ops returns the operations for the paths and values on the command line.
+func (cfg *config) ops() []sjson.Op {
+	var ops []sjson.Op
+	if cfg.del {
+		for _, path := range cfg.args {
+			ops = append(ops, sjson.Op{Kind: sjson.OpDelete, Path: path})
+		}
+		return ops
+	}
+	for i := 0; i < len(cfg.args); i += 2 {
+		path, value := cfg.args[i], cfg.args[i+1]
+		switch {
+		case cfg.str:
+			ops = append(ops, sjson.Op{Kind: sjson.OpSet, Path: path,
+				Value: value})
+		case cfg.raw || gjson.Valid(value):
+			ops = append(ops, sjson.Op{Kind: sjson.OpSetRaw, Path: path,
+				Value: strings.TrimSpace(value)})
+		default:
+			ops = append(ops, sjson.Op{Kind: sjson.OpSet, Path: path,
+				Value: value})
+		}
+	}
+	return ops
+}
+
+// edit reads the document, applies the operations and writes the result.
+func edit(cfg *config, stdin io.Reader, stdout io.Writer) error {
+	in := stdin
+	if cfg.file != "" {
+		f, err := os.Open(cfg.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if !cfg.inPlace {
+		return write(cfg, stdout, in)
+	}
+	info, err := os.Stat(cfg.file)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file next to the original, and replace the
+	// original only when everything succeeded.
+	tmp, err := ioutil.TempFile(filepath.Dir(cfg.file),
+		"."+filepath.Base(cfg.file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(cfg, tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cfg.file)
+}
+
+// write writes the edited document to w.
+func write(cfg *config, w io.Writer, r io.Reader) error {
+	if cfg.ndjson {
+		return sjson.EditLines(w, r, cfg.ops(),
+			&sjson.LinesOptions{Filter: cfg.filter})
+	}
+	json, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	res, err := sjson.SetManyBytes(json, cfg.ops())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(res)
+	return err
+}
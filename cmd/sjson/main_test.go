@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runTest(t *testing.T, stdin string, args ...string) (string, string,
+	int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestSet(t *testing.T) {
+	json := "{\n  \"name\": {\"first\": \"Tom\", \"last\": \"Smith\"},\n" +
+		"  \"age\": 37\n}\n"
+	tests := []struct {
+		args   []string
+		expect string
+	}{
+		{[]string{"set", "name.last", `"Anderson"`},
+			"{\n  \"name\": {\"first\": \"Tom\", \"last\": \"Anderson\"},\n" +
+				"  \"age\": 37\n}\n"},
+		{[]string{"set", "name.last", "Anderson", "age", "-38"},
+			"{\n  \"name\": {\"first\": \"Tom\", \"last\": \"Anderson\"},\n" +
+				"  \"age\": -38\n}\n"},
+		{[]string{"set", "--string", "age", "38"},
+			"{\n  \"name\": {\"first\": \"Tom\", \"last\": \"Smith\"},\n" +
+				"  \"age\": \"38\"\n}\n"},
+		{[]string{"set", "-", "name", "--raw", `{"x":1}`},
+			"{\n  \"name\": {\"x\":1},\n  \"age\": 37\n}\n"},
+		{[]string{"delete", "-", "name.first", "--", "age"},
+			"{\n  \"name\": { \"last\": \"Smith\"}\n}\n"},
+		{[]string{"set", "--delete", "name"},
+			"{\n  \"age\": 37\n}\n"},
+		{[]string{"delete", "name", "age"}, "{\n}\n"},
+		{[]string{"delete", "--", "name.first", "name.last"},
+			"{\n  \"name\": {},\n  \"age\": 37\n}\n"},
+	}
+	for i, tt := range tests {
+		stdout, stderr, code := runTest(t, json, tt.args...)
+		if code != 0 {
+			t.Fatalf("test %d: exit code %d: %s", i, code, stderr)
+		}
+		if stdout != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, stdout)
+		}
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.json")
+	json := "{\n  // not json, but kept as is\n  \"port\": 80\n}\n"
+	if err := ioutil.WriteFile(file, []byte(json), 0640); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := runTest(t, "", "set", "--in-place", file,
+		"port", "8080")
+	if code != 0 || stdout != "" {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := strings.Replace(json, "80", "8080", 1)
+	if string(data) != expect {
+		t.Fatalf("expected '%v', got '%s'", expect, data)
+	}
+	info, err := os.Stat(file)
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("unexpected mode %v, %v", info.Mode(), err)
+	}
+	file = filepath.Join(dir, "data.json")
+	if err := ioutil.WriteFile(file, []byte(`{"a":1,"b":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code = runTest(t, "", "delete", file, "a")
+	if code != 0 || stdout != `{"b":2}` {
+		t.Fatalf("exit code %d: '%s' %s", code, stdout, stderr)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	input := "{\"level\":\"info\"}\n{\"level\":\"error\"}\n"
+	stdout, stderr, code := runTest(t, input, "set", "--ndjson",
+		"--filter", `level=="error"`, "alert", "true")
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	expect := "{\"level\":\"info\"}\n{\"level\":\"error\",\"alert\":true}\n"
+	if stdout != expect {
+		t.Fatalf("expected '%v', got '%v'", expect, stdout)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"get", "name"},
+		{"set", "name"},
+		{"set", "--in-place", "name", "Tom"},
+		{"set", "--raw", "--string", "name", "Tom"},
+		{"set", "--filter", "a==1", "name", "Tom"},
+		{"set", "--bogus", "name", "Tom"},
+	}
+	for i, args := range tests {
+		if _, _, code := runTest(t, "{}", args...); code != 2 {
+			t.Fatalf("test %d: expected exit code 2, got %d", i, code)
+		}
+	}
+	_, stderr, code := runTest(t, `{"a":[]}`, "set", "a.b", "1")
+	if code != 1 || !strings.Contains(stderr, "non-numeric key") {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr)
+	}
+}
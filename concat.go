@@ -0,0 +1,258 @@
+package sjson
+
+import (
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// concatStringOp returns the operation that adds s to the end, or to the
+// start, of a string.
+func concatStringOp(s string, prepend bool) setOp {
+	quoted := string(appendStringify(nil, s))
+	op := setOp{raw: quoted}
+	op.update = func(old string) (string, error) {
+		if len(old) < 2 || old[0] != '"' {
+			return "", ErrNotString
+		}
+		inner := quoted[1 : len(quoted)-1]
+		if prepend {
+			return `"` + inner + old[1:], nil
+		}
+		return old[:len(old)-1] + inner + `"`, nil
+	}
+	return op
+}
+
+// ConcatString adds s to the end of the string at the specified path. The
+// text is escaped as needed. The string is created when the path does not
+// exist, and ErrNotString is returned when the value is not a string.
+//
+//	ConcatString(`{"log":"started"}`, "log", ", stopped")
+//	>> {"log":"started, stopped"}
+func ConcatString(json, path, s string) (string, error) {
+	op := concatStringOp(s, false)
+	return applyString(json, path, &op)
+}
+
+// ConcatStringBytes adds s to the end of the string at the specified path.
+// If working with bytes, this method preferred over
+// ConcatString(string(data), path, s)
+func ConcatStringBytes(json []byte, path, s string) ([]byte, error) {
+	op := concatStringOp(s, false)
+	return applyBytes(json, path, &op)
+}
+
+// PrependString adds s to the start of the string at the specified path.
+func PrependString(json, path, s string) (string, error) {
+	op := concatStringOp(s, true)
+	return applyString(json, path, &op)
+}
+
+// PrependStringBytes adds s to the start of the string at the specified
+// path. If working with bytes, this method preferred over
+// PrependString(string(data), path, s)
+func PrependStringBytes(json []byte, path, s string) ([]byte, error) {
+	op := concatStringOp(s, true)
+	return applyBytes(json, path, &op)
+}
+
+// closeIndex returns the index of the closing bracket of a container, and
+// the index just after its last member.
+func closeIndex(raw string) (end, last int) {
+	end = len(raw) - 1
+	last = end
+	for last > 0 && raw[last-1] <= ' ' {
+		last--
+	}
+	return end, last
+}
+
+// concatOp returns the operation that adds the elements of the raw array to
+// an array.
+func concatOp(raw string) (setOp, error) {
+	vals := gjson.Parse(raw)
+	if !vals.IsArray() {
+		return setOp{}, &PathError{Component: -1, Offset: -1,
+			Err: ErrInvalidValue}
+	}
+	_, vlast := closeIndex(vals.Raw)
+	elems := trim(vals.Raw[1:vlast])
+	op := setOp{raw: vals.Raw}
+	op.update = func(old string) (string, error) {
+		if old[0] != '[' {
+			return "", ErrNotArray
+		}
+		if elems == "" {
+			return old, nil
+		}
+		_, last := closeIndex(old)
+		sep := ","
+		if last == 1 {
+			sep = ""
+		}
+		return old[:last] + sep + elems + old[last:], nil
+	}
+	return op, nil
+}
+
+// Concat adds all of the elements of values, which must marshal to a json
+// array, to the end of the array at the specified path. This is the same as
+// appending each element using the -1 key, but in a single pass. The array
+// is created when the path does not exist, and ErrNotArray is returned when
+// the value is not an array.
+//
+//	Concat(`{"tags":["a"]}`, "tags", []string{"b", "c"})
+//	>> {"tags":["a","b","c"]}
+func Concat(json, path string, values interface{}) (string, error) {
+	op, err := rawValue(values)
+	if err != nil {
+		return json, err
+	}
+	if op.stringify {
+		return json, &PathError{Component: -1, Offset: -1,
+			Err: ErrInvalidValue}
+	}
+	return ConcatRaw(json, path, op.raw)
+}
+
+// ConcatBytes adds all of the elements of values to the end of the array at
+// the specified path. If working with bytes, this method preferred over
+// Concat(string(data), path, values)
+func ConcatBytes(json []byte, path string, values interface{}) ([]byte,
+	error) {
+	op, err := rawValue(values)
+	if err != nil {
+		return json, err
+	}
+	if op.stringify {
+		return json, &PathError{Component: -1, Offset: -1,
+			Err: ErrInvalidValue}
+	}
+	return ConcatRawBytes(json, path, []byte(op.raw))
+}
+
+// ConcatRaw adds all of the elements of the raw json array to the end of the
+// array at the specified path.
+func ConcatRaw(json, path, values string) (string, error) {
+	op, err := concatOp(values)
+	if err != nil {
+		return json, err
+	}
+	return applyString(json, path, &op)
+}
+
+// ConcatRawBytes adds all of the elements of the raw json array to the end
+// of the array at the specified path. If working with bytes, this method
+// preferred over ConcatRaw(string(data), path, values)
+func ConcatRawBytes(json []byte, path string, values []byte) ([]byte, error) {
+	op, err := concatOp(*(*string)(unsafe.Pointer(&values)))
+	if err != nil {
+		return json, err
+	}
+	return applyBytes(json, path, &op)
+}
+
+// mergeOp returns the operation that sets each member of the raw object in
+// an object.
+func mergeOp(raw string) (setOp, error) {
+	obj := gjson.Parse(raw)
+	if !obj.IsObject() {
+		return setOp{}, &PathError{Component: -1, Offset: -1,
+			Err: ErrInvalidValue}
+	}
+	op := setOp{raw: obj.Raw}
+	op.update = func(old string) (string, error) {
+		if old[0] != '{' {
+			return "", ErrNotObject
+		}
+		var err error
+		obj.ForEach(func(key, value gjson.Result) bool {
+			paths := []pathResult{{
+				part:  key.Str,
+				gpart: escapeComp(key.Str),
+				force: true,
+			}}
+			old, err = setPaths(old, paths, setOp{raw: value.Raw})
+			return err == nil
+		})
+		return old, err
+	}
+	return op, nil
+}
+
+// Merge sets each member of value, which must marshal to a json object, in
+// the object at the specified path. Existing members are replaced and new
+// members are added. Unlike MergePatch, nested objects are replaced rather
+// than merged and null values are set rather than deleted. The object is
+// created when the path does not exist, and ErrNotObject is returned when
+// the value is not an object.
+//
+//	Merge(`{"user":{"name":"Tom","age":37}}`, "user",
+//	    map[string]interface{}{"age": 38})
+//	>> {"user":{"name":"Tom","age":38}}
+func Merge(json, path string, value interface{}) (string, error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return json, err
+	}
+	if op.stringify {
+		return json, &PathError{Component: -1, Offset: -1,
+			Err: ErrInvalidValue}
+	}
+	return MergeRaw(json, path, op.raw)
+}
+
+// MergeBytes sets each member of value in the object at the specified path.
+// If working with bytes, this method preferred over
+// Merge(string(data), path, value)
+func MergeBytes(json []byte, path string, value interface{}) ([]byte, error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return json, err
+	}
+	if op.stringify {
+		return json, &PathError{Component: -1, Offset: -1,
+			Err: ErrInvalidValue}
+	}
+	return MergeRawBytes(json, path, []byte(op.raw))
+}
+
+// MergeRaw sets each member of the raw json object in the object at the
+// specified path.
+func MergeRaw(json, path, value string) (string, error) {
+	op, err := mergeOp(value)
+	if err != nil {
+		return json, err
+	}
+	return applyString(json, path, &op)
+}
+
+// MergeRawBytes sets each member of the raw json object in the object at the
+// specified path. If working with bytes, this method preferred over
+// MergeRaw(string(data), path, value)
+func MergeRawBytes(json []byte, path string, value []byte) ([]byte, error) {
+	op, err := mergeOp(*(*string)(unsafe.Pointer(&value)))
+	if err != nil {
+		return json, err
+	}
+	return applyBytes(json, path, &op)
+}
+
+// applyString performs the operation on json.
+func applyString(json, path string, op *setOp) (string, error) {
+	res, err := set(json, path, op, nil)
+	if err == errNoChange {
+		return json, nil
+	}
+	return string(res), err
+}
+
+// applyBytes performs the operation on json.
+func applyBytes(json []byte, path string, op *setOp) ([]byte, error) {
+	res, err := set(*(*string)(unsafe.Pointer(&json)), path, op, nil)
+	if err == errNoChange {
+		return json, nil
+	}
+	return res, err
+}
@@ -0,0 +1,94 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConcatString(t *testing.T) {
+	json, err := ConcatString(`{"a":"x"}`, "a", "y\"z")
+	if err != nil || json != `{"a":"xy\"z"}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	json, err = PrependString(`{"a":"x"}`, "a", "\n")
+	if err != nil || json != `{"a":"\nx"}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	json, err = ConcatString(`{}`, "a.b", "x")
+	if err != nil || json != `{"a":{"b":"x"}}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	b, err := ConcatStringBytes([]byte(`{"a":["x","y"]}`), "a.#()#", "!")
+	if err != nil || string(b) != `{"a":["x!","y!"]}` {
+		t.Fatalf("unexpected result '%s', %v", b, err)
+	}
+	_, err = ConcatString(`{"a":1}`, "a", "x")
+	if !errors.Is(err, ErrNotString) {
+		t.Fatalf("expected ErrNotString, got %v", err)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	tests := []struct {
+		json, path, values, expect string
+	}{
+		{`{"a":[1]}`, "a", `[2,3]`, `{"a":[1,2,3]}`},
+		{`{"a":[]}`, "a", `[2,3]`, `{"a":[2,3]}`},
+		{`{"a":[ ]}`, "a", `[ 2 ]`, `{"a":[2 ]}`},
+		{`{"a":[1]}`, "a", `[]`, `{"a":[1]}`},
+		{"{\"a\":[\n  1\n]}", "a", `[2]`, "{\"a\":[\n  1,2\n]}"},
+		{`{}`, "a", `[2,3]`, `{"a":[2,3]}`},
+	}
+	for i, tt := range tests {
+		json, err := ConcatRaw(tt.json, tt.path, tt.values)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+	}
+	json, err := Concat(`{"a":["x"]}`, "a", []string{"y", "z"})
+	if err != nil || json != `{"a":["x","y","z"]}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	_, err = Concat(`{"a":{}}`, "a", []int{1})
+	if !errors.Is(err, ErrNotArray) {
+		t.Fatalf("expected ErrNotArray, got %v", err)
+	}
+	_, err = Concat(`{"a":[]}`, "a", 1)
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		json, path, value, expect string
+	}{
+		{`{"a":{"b":1,"c":2}}`, "a", `{"c":3,"d":4}`,
+			`{"a":{"b":1,"c":3,"d":4}}`},
+		{`{"a":{"b":{"x":1}}}`, "a", `{"b":{"y":2}}`, `{"a":{"b":{"y":2}}}`},
+		{`{"a":{}}`, "a", `{"0":1,"a.b":2,"":3}`,
+			`{"a":{"0":1,"a.b":2,"":3}}`},
+		{`{"a":{"b":1}}`, "a", `{"b":null}`, `{"a":{"b":null}}`},
+		{`{}`, "a", `{"b":1}`, `{"a":{"b":1}}`},
+	}
+	for i, tt := range tests {
+		json, err := MergeRaw(tt.json, tt.path, tt.value)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+	}
+	json, err := Merge(`{"a":{"b":1}}`, "a", map[string]int{"c": 2})
+	if err != nil || json != `{"a":{"b":1,"c":2}}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	_, err = Merge(`{"a":[]}`, "a", map[string]int{"c": 2})
+	if !errors.Is(err, ErrNotObject) {
+		t.Fatalf("expected ErrNotObject, got %v", err)
+	}
+}
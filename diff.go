@@ -0,0 +1,191 @@
+package sjson
+
+import (
+	"strconv"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// appendPointerToken appends a key to a JSON Pointer, escaping the '~' and
+// '/' characters.
+func appendPointerToken(ptr []byte, key string) []byte {
+	ptr = append(ptr, '/')
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '~':
+			ptr = append(ptr, '~', '0')
+		case '/':
+			ptr = append(ptr, '~', '1')
+		default:
+			ptr = append(ptr, key[i])
+		}
+	}
+	return ptr
+}
+
+// appendPatchOp appends a single JSON Patch operation to buf.
+func appendPatchOp(buf []byte, op string, ptr []byte, value string) []byte {
+	if len(buf) > 1 {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, `{"op":"`...)
+	buf = append(buf, op...)
+	buf = append(buf, `","path":`...)
+	buf = appendStringify(buf, *(*string)(unsafe.Pointer(&ptr)))
+	if op != "remove" {
+		buf = append(buf, `,"value":`...)
+		buf = append(buf, value...)
+	}
+	return append(buf, '}')
+}
+
+// appendDiff appends the operations that turn a into b.
+func appendDiff(buf, ptr []byte, a, b gjson.Result) []byte {
+	if a.Raw == b.Raw {
+		return buf
+	}
+	switch {
+	case a.IsObject() && b.IsObject():
+		am, bm := a.Map(), b.Map()
+		a.ForEach(func(key, _ gjson.Result) bool {
+			if _, ok := bm[key.Str]; !ok {
+				buf = appendPatchOp(buf, "remove",
+					appendPointerToken(ptr, key.Str), "")
+			}
+			return true
+		})
+		b.ForEach(func(key, bv gjson.Result) bool {
+			if av, ok := am[key.Str]; ok {
+				buf = appendDiff(buf, appendPointerToken(ptr, key.Str), av, bv)
+			} else {
+				buf = appendPatchOp(buf, "add",
+					appendPointerToken(ptr, key.Str), bv.Raw)
+			}
+			return true
+		})
+	case a.IsArray() && b.IsArray():
+		aa, ba := a.Array(), b.Array()
+		// skip the elements that are the same at the start and end
+		var p, s int
+		for p < len(aa) && p < len(ba) && aa[p].Raw == ba[p].Raw {
+			p++
+		}
+		for s < len(aa)-p && s < len(ba)-p &&
+			aa[len(aa)-1-s].Raw == ba[len(ba)-1-s].Raw {
+			s++
+		}
+		aa, ba = aa[p:len(aa)-s], ba[p:len(ba)-s]
+		m := len(aa)
+		if len(ba) < m {
+			m = len(ba)
+		}
+		for i := 0; i < m; i++ {
+			buf = appendDiff(buf, appendPointerToken(ptr,
+				strconv.Itoa(p+i)), aa[i], ba[i])
+		}
+		for i := m; i < len(aa); i++ {
+			buf = appendPatchOp(buf, "remove",
+				appendPointerToken(ptr, strconv.Itoa(p+m)), "")
+		}
+		for i := m; i < len(ba); i++ {
+			buf = appendPatchOp(buf, "add",
+				appendPointerToken(ptr, strconv.Itoa(p+i)), ba[i].Raw)
+		}
+	default:
+		buf = appendPatchOp(buf, "replace", ptr, b.Raw)
+	}
+	return buf
+}
+
+// appendMergeDiff appends the merge patch that turns a into b.
+func appendMergeDiff(buf []byte, a, b gjson.Result) []byte {
+	if !a.IsObject() || !b.IsObject() {
+		return append(buf, b.Raw...)
+	}
+	am, bm := a.Map(), b.Map()
+	buf = append(buf, '{')
+	n := len(buf)
+	a.ForEach(func(key, _ gjson.Result) bool {
+		if _, ok := bm[key.Str]; !ok {
+			if len(buf) > n {
+				buf = append(buf, ',')
+			}
+			buf = appendStringify(buf, key.Str)
+			buf = append(buf, ":null"...)
+		}
+		return true
+	})
+	b.ForEach(func(key, bv gjson.Result) bool {
+		av, ok := am[key.Str]
+		if ok && av.Raw == bv.Raw {
+			return true
+		}
+		if len(buf) > n {
+			buf = append(buf, ',')
+		}
+		buf = appendStringify(buf, key.Str)
+		buf = append(buf, ':')
+		if ok {
+			buf = appendMergeDiff(buf, av, bv)
+		} else {
+			buf = append(buf, bv.Raw...)
+		}
+		return true
+	})
+	return append(buf, '}')
+}
+
+// Diff returns a JSON Patch (RFC 6902) document that describes the changes
+// needed to turn the old json into the new json, such that
+// ApplyPatch(old, patch) produces the new json.
+// Values in the patch are copied byte-for-byte from the new json.
+// Array changes are found by comparing elements by position after skipping
+// the elements that are the same at the start and at the end of the array.
+// An error is returned if either json is not valid.
+func Diff(old, new string) (string, error) {
+	if !gjson.Valid(old) || !gjson.Valid(new) {
+		return "", ErrInvalidJSON
+	}
+	buf := appendDiff([]byte{'['}, nil, gjson.Parse(old), gjson.Parse(new))
+	return string(append(buf, ']')), nil
+}
+
+// DiffBytes returns a JSON Patch (RFC 6902) document that describes the
+// changes needed to turn the old json into the new json.
+// If working with bytes, this method preferred over
+// Diff(string(old), string(new))
+func DiffBytes(old, new []byte) ([]byte, error) {
+	if !gjson.ValidBytes(old) || !gjson.ValidBytes(new) {
+		return nil, ErrInvalidJSON
+	}
+	buf := appendDiff([]byte{'['}, nil, gjson.ParseBytes(old),
+		gjson.ParseBytes(new))
+	return append(buf, ']'), nil
+}
+
+// MergeDiff returns a JSON Merge Patch (RFC 7386) document that describes
+// the changes needed to turn the old json into the new json, such that
+// MergePatch(old, patch) produces the new json.
+// Merge patches cannot express setting a member to null, nor changes
+// inside of arrays, so such values are replaced as a whole.
+// An error is returned if either json is not valid.
+func MergeDiff(old, new string) (string, error) {
+	if !gjson.Valid(old) || !gjson.Valid(new) {
+		return "", ErrInvalidJSON
+	}
+	return string(appendMergeDiff(nil, gjson.Parse(old),
+		gjson.Parse(new))), nil
+}
+
+// MergeDiffBytes returns a JSON Merge Patch (RFC 7386) document that
+// describes the changes needed to turn the old json into the new json.
+// If working with bytes, this method preferred over
+// MergeDiff(string(old), string(new))
+func MergeDiffBytes(old, new []byte) ([]byte, error) {
+	if !gjson.ValidBytes(old) || !gjson.ValidBytes(new) {
+		return nil, ErrInvalidJSON
+	}
+	return appendMergeDiff(nil, gjson.ParseBytes(old),
+		gjson.ParseBytes(new)), nil
+}
@@ -0,0 +1,142 @@
+package sjson
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		old, new, expect string
+	}{
+		{`{"a":1}`, `{"a":1}`, `[]`},
+		{`{"a":1}`, `{"a":2}`, `[{"op":"replace","path":"/a","value":2}]`},
+		{`{"a":1,"b":2}`, `{"b":2}`, `[{"op":"remove","path":"/a"}]`},
+		{`{"a":1}`, `{"a":1,"b/c":{"d":2}}`,
+			`[{"op":"add","path":"/b~1c","value":{"d":2}}]`},
+		{`[1,2,3]`, `[1,4,2,3]`, `[{"op":"add","path":"/1","value":4}]`},
+		{`[1,2,3]`, `[1,3]`, `[{"op":"remove","path":"/1"}]`},
+		{`[1,2,3,4]`, `[1,4]`,
+			`[{"op":"remove","path":"/1"},{"op":"remove","path":"/1"}]`},
+		{`[{"a":1},{"a":2}]`, `[{"a":1},{"a":3}]`,
+			`[{"op":"replace","path":"/1/a","value":3}]`},
+		{`{"a":[1]}`, `{"a":{"0":1}}`,
+			`[{"op":"replace","path":"/a","value":{"0":1}}]`},
+		{`1`, `"a"`, `[{"op":"replace","path":"","value":"a"}]`},
+	}
+	for i, tt := range tests {
+		patch, err := Diff(tt.old, tt.new)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if patch != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, patch)
+		}
+		patchb, err := DiffBytes([]byte(tt.old), []byte(tt.new))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if string(patchb) != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, patchb)
+		}
+		res, err := ApplyPatch(tt.old, patch)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.new {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.new, res)
+		}
+	}
+}
+
+func TestDiffDashKey(t *testing.T) {
+	tests := [][2]string{
+		{`{}`, `{"-":{}}`},
+		{`{"-":1}`, `{"-":2}`},
+		{`{"-":1,"a":2}`, `{"a":2}`},
+		{`{"-":[1]}`, `{"-":[1,2],"x":{"-":3}}`},
+	}
+	for i, tt := range tests {
+		patch, err := Diff(tt[0], tt[1])
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		res, err := ApplyPatch(tt[0], patch)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if !jsonEqual(gjson.Parse(res), gjson.Parse(tt[1])) {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt[1], res)
+		}
+	}
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	new := `
+	{
+		"name": {"first": "Tom", "middle": "J", "last": "Smith"},
+		"children": ["Sara","Jack","Alex"],
+		"fav.movie": "Deer Hunter",
+		"friends": [
+		  {"first": "Dale", "last": "Murphy", "age": 45, "nets": ["ig", "tw"]},
+		  {"first": "Jane", "last": "Murphy", "age": 47, "nets": ["ig", "tw"]}
+		],
+		"tags": null
+	}`
+	patch, err := Diff(example, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ApplyPatch(example, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(gjson.Parse(res), gjson.Parse(new)) {
+		t.Fatalf("expected '%v', got '%v'", new, res)
+	}
+	mpatch, err := MergeDiff(example, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = MergePatch(example, mpatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the null "tags" member cannot be expressed by a merge patch
+	res, _ = Set(res, "tags", nil)
+	if !jsonEqual(gjson.Parse(res), gjson.Parse(new)) {
+		t.Fatalf("expected '%v', got '%v'", new, res)
+	}
+}
+
+func TestMergeDiff(t *testing.T) {
+	tests := []struct {
+		old, new, expect string
+	}{
+		{`{"a":1}`, `{"a":1}`, `{}`},
+		{`{"a":1,"b":{"c":1,"d":2}}`, `{"b":{"c":1,"d":3},"e":[1]}`,
+			`{"a":null,"b":{"d":3},"e":[1]}`},
+		{`{"a":[1,2]}`, `{"a":[1,3]}`, `{"a":[1,3]}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+	}
+	for i, tt := range tests {
+		patch, err := MergeDiff(tt.old, tt.new)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if patch != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, patch)
+		}
+		patchb, err := MergeDiffBytes([]byte(tt.old), []byte(tt.new))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if string(patchb) != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, patchb)
+		}
+	}
+	if _, err := MergeDiff(`{`, `{}`); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,105 @@
+package sjson
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrEmptyPath is returned when the path is empty.
+	ErrEmptyPath = errors.New("path cannot be empty")
+	// ErrNotContainer is returned when a value cannot be added because the
+	// json that it is added to is not an object or array.
+	ErrNotContainer = errors.New("json must be an object or array")
+	// ErrNonNumericKey is returned when an array element is set using a
+	// key that is not a number.
+	ErrNonNumericKey = errors.New(
+		"cannot set array element for non-numeric key")
+	// ErrComplexPath is returned when an operation does not support paths
+	// with queries, wildcards or modifiers.
+	ErrComplexPath = errors.New("operation does not support complex paths")
+	// ErrInvalidValue is returned when a value has the wrong type for the
+	// operation.
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrInvalidJSON is returned when a json document that must be valid is
+	// not.
+	ErrInvalidJSON = errors.New("invalid json")
+	// ErrInvalidPointer is returned when a JSON Pointer is not valid.
+	ErrInvalidPointer = errors.New("invalid json pointer")
+	// ErrInvalidPatch is returned when a JSON Patch document is not an array
+	// of operations.
+	ErrInvalidPatch = errors.New("patch must be an array of operations")
+	// ErrInvalidOperation is returned when a JSON Patch operation is unknown
+	// or is missing a member.
+	ErrInvalidOperation = errors.New("invalid operation")
+	// ErrNotFound is returned when a path that must exist does not.
+	ErrNotFound = errors.New("path not found")
+	// ErrMoveIntoSelf is returned when a value is moved into one of its own
+	// children.
+	ErrMoveIntoSelf = errors.New("cannot move a value into itself")
+	// ErrNotNumber is returned when a number is expected but the value is
+	// not a number.
+	ErrNotNumber = errors.New("value is not a number")
+	// ErrNotString is returned when a string is expected but the value is
+	// not a string.
+	ErrNotString = errors.New("value is not a string")
+	// ErrNotArray is returned when an array is expected but the value is
+	// not an array.
+	ErrNotArray = errors.New("value is not an array")
+	// ErrNotObject is returned when an object is expected but the value is
+	// not an object.
+	ErrNotObject = errors.New("value is not an object")
+	// ErrKeyExists is returned when a key cannot be renamed because the
+	// object already has a member with the new key.
+	ErrKeyExists = errors.New("key already exists")
+	// ErrConditionFailed is returned when a conditional set does not meet
+	// its condition.
+	ErrConditionFailed = errors.New("condition failed")
+	// ErrTestFailed is returned when a JSON Patch "test" operation fails.
+	ErrTestFailed = errors.New("test failed")
+)
+
+// errNoChange is used internally when an operation leaves the json as is.
+var errNoChange = errors.New("no change")
+
+// PathError records an error and the path that caused it.
+// The Err field holds one of the Err values, for use with errors.Is.
+type PathError struct {
+	Path      string // the path of the operation
+	Component int    // index of the failing path component, or -1
+	Key       string // the failing path component
+	Offset    int    // byte offset of the affected value in the json, or -1
+	Err       error  // reason for the error
+}
+
+func (err *PathError) Error() string {
+	msg := err.Err.Error()
+	if err.Err == ErrNonNumericKey {
+		msg += " '" + err.Key + "'"
+	}
+	return msg
+}
+
+// Unwrap returns the reason for the error.
+func (err *PathError) Unwrap() error {
+	return err.Err
+}
+
+// PatchError is returned by ApplyPatch when an operation in the patch could
+// not be applied.
+type PatchError struct {
+	Index int    // index of the operation in the patch
+	Op    string // name of the operation, such as "add" or "test"
+	Path  string // path of the operation, as a JSON Pointer
+	Err   error  // reason the operation failed
+}
+
+func (err *PatchError) Error() string {
+	return "patch operation " + strconv.Itoa(err.Index) + " (" + err.Op +
+		" '" + err.Path + "'): " + err.Err.Error()
+}
+
+// Unwrap returns the reason the operation failed.
+func (err *PatchError) Unwrap() error {
+	return err.Err
+}
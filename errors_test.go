@@ -0,0 +1,44 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPathError(t *testing.T) {
+	_, err := Set(`{"a":{"b":[1,2]}}`, "a.b.c", 1)
+	var perr *PathError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *PathError, got %v", err)
+	}
+	if !errors.Is(err, ErrNonNumericKey) {
+		t.Fatalf("expected ErrNonNumericKey, got %v", perr.Err)
+	}
+	if perr.Path != "a.b.c" || perr.Component != 2 || perr.Key != "c" ||
+		perr.Offset != 10 {
+		t.Fatalf("unexpected error fields %+v", perr)
+	}
+	if err.Error() != "cannot set array element for non-numeric key 'c'" {
+		t.Fatalf("unexpected error message '%v'", err)
+	}
+	_, err = Set(`{}`, "", 1)
+	if !errors.Is(err, ErrEmptyPath) {
+		t.Fatalf("expected ErrEmptyPath, got %v", err)
+	}
+	_, err = Insert(`{"a":[1]}`, "a.#(==1)", 1)
+	if !errors.Is(err, ErrComplexPath) {
+		t.Fatalf("expected ErrComplexPath, got %v", err)
+	}
+	_, err = SetMany(`{}`, []Op{{Kind: OpSetRaw, Path: "a", Value: 1}})
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+	_, err = ApplyPatch(`{}`, `[{"op":"remove","path":"/a"}]`)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	_, err = MergePatch(`{}`, `{`)
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("expected ErrInvalidJSON, got %v", err)
+	}
+}
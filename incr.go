@@ -0,0 +1,202 @@
+package sjson
+
+import (
+	jsongo "encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// isNumber returns true if s is a valid json number.
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	i, msg := validNumber(s, 0)
+	return msg == "" && i == len(s)
+}
+
+// deltaRaw returns the json number for an Increment delta. The float return
+// value is true if the delta is a floating-point number.
+func deltaRaw(delta interface{}) (raw string, float bool, err error) {
+	switch v := delta.(type) {
+	case int:
+		return strconv.FormatInt(int64(v), 10), false, nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), false, nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), false, nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), false, nil
+	case int64:
+		return strconv.FormatInt(v, 10), false, nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), false, nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), false, nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), false, nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), false, nil
+	case uint64:
+		return strconv.FormatUint(v, 10), false, nil
+	case float32:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return "", false, ErrInvalidValue
+		}
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return "", false, ErrInvalidValue
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), true, nil
+	case jsongo.Number:
+		raw = string(v)
+	case string:
+		raw = v
+	default:
+		return "", false, ErrInvalidValue
+	}
+	if !isNumber(raw) {
+		return "", false, ErrInvalidValue
+	}
+	return raw, strings.ContainsAny(raw, ".eE"), nil
+}
+
+// floatRaw makes sure that a json number has a floating-point form.
+func floatRaw(raw string) string {
+	if strings.ContainsAny(raw, ".eE") {
+		return raw
+	}
+	return raw + ".0"
+}
+
+// parseDecimal returns the digits of a json number without an exponent as
+// an integer, and the number of digits after the decimal point.
+func parseDecimal(raw string) (*big.Int, int) {
+	scale := 0
+	if dot := strings.IndexByte(raw, '.'); dot != -1 {
+		scale = len(raw) - dot - 1
+		raw = raw[:dot] + raw[dot+1:]
+	}
+	n, _ := new(big.Int).SetString(raw, 10)
+	return n, scale
+}
+
+// addNumbers adds two json numbers. Integers and decimals are added
+// exactly, keeping the largest number of decimal places. Numbers with an
+// exponent are added as float64 values.
+func addNumbers(a, b string, float bool) (string, error) {
+	if !isNumber(a) {
+		return "", ErrNotNumber
+	}
+	float = float || strings.ContainsAny(a, ".eE")
+	if strings.ContainsAny(a, "eE") || strings.ContainsAny(b, "eE") {
+		x, _ := strconv.ParseFloat(a, 64)
+		y, _ := strconv.ParseFloat(b, 64)
+		z := x + y
+		if math.IsInf(z, 0) || math.IsNaN(z) {
+			return "", ErrInvalidValue
+		}
+		return floatRaw(strconv.FormatFloat(z, 'g', -1, 64)), nil
+	}
+	x, xscale := parseDecimal(a)
+	y, yscale := parseDecimal(b)
+	scale := xscale
+	for ; scale < yscale; scale++ {
+		x.Mul(x, big.NewInt(10))
+	}
+	for ; yscale < scale; yscale++ {
+		y.Mul(y, big.NewInt(10))
+	}
+	digits := x.Add(x, y).String()
+	if scale == 0 {
+		if float {
+			return digits + ".0", nil
+		}
+		return digits, nil
+	}
+	var neg string
+	if digits[0] == '-' {
+		neg, digits = "-", digits[1:]
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return neg + digits[:len(digits)-scale] + "." +
+		digits[len(digits)-scale:], nil
+}
+
+// incrementOp returns the operation that adds delta to a number.
+func incrementOp(delta interface{}) (setOp, error) {
+	raw, float, err := deltaRaw(delta)
+	if err != nil {
+		return setOp{}, &PathError{Component: -1, Offset: -1, Err: err}
+	}
+	op := setOp{raw: raw}
+	if float {
+		op.raw = floatRaw(raw)
+	}
+	op.update = func(old string) (string, error) {
+		return addNumbers(old, raw, float)
+	}
+	return op, nil
+}
+
+// Increment adds delta to the number at the specified path. The delta may
+// be any integer or floating-point type, or a string or json.Number holding
+// a json number. A negative delta decrements the number.
+//
+// Integers and decimals are added exactly, without a conversion to float64,
+// so large integers keep their precision. The result is an integer only when
+// both the existing number and the delta are integers.
+//
+//	Increment(`{"views":9007199254740993}`, "views", 1)
+//	>> {"views":9007199254740994}
+//	Increment(`{"price":1.10}`, "price", 0.2)
+//	>> {"price":1.30}
+//
+// When the path does not exist it is created with the delta as its value.
+// ErrNotNumber is returned when the existing value is not a number.
+func Increment(json, path string, delta interface{}) (string, error) {
+	return IncrementOptions(json, path, delta, nil)
+}
+
+// IncrementBytes adds delta to the number at the specified path.
+// If working with bytes, this method preferred over
+// Increment(string(data), path, delta)
+func IncrementBytes(json []byte, path string, delta interface{}) ([]byte,
+	error) {
+	return IncrementBytesOptions(json, path, delta, nil)
+}
+
+// IncrementOptions adds delta to the number at the specified path with
+// options.
+func IncrementOptions(json, path string, delta interface{},
+	opts *Options) (string, error) {
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, err := IncrementBytesOptions(jsonb, path, delta, stringOptions(opts))
+	return string(res), err
+}
+
+// IncrementBytesOptions adds delta to the number at the specified path with
+// options. If working with bytes, this method preferred over
+// IncrementOptions(string(data), path, delta, opts)
+func IncrementBytesOptions(json []byte, path string, delta interface{},
+	opts *Options) ([]byte, error) {
+	op, err := incrementOp(delta)
+	if err != nil {
+		return json, err
+	}
+	jstr := *(*string)(unsafe.Pointer(&json))
+	op.inPlace(json, opts)
+	res, err := set(jstr, path, &op, opts)
+	if err == errNoChange {
+		return json, nil
+	}
+	return res, err
+}
@@ -0,0 +1,60 @@
+package sjson
+
+import (
+	jsongo "encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestIncrement(t *testing.T) {
+	tests := []struct {
+		json, path string
+		delta      interface{}
+		expect     string
+	}{
+		{`{"a":1}`, "a", 1, `{"a":2}`},
+		{`{"a":1}`, "a", -3, `{"a":-2}`},
+		{`{"a":9007199254740993}`, "a", int64(1), `{"a":9007199254740994}`},
+		{`{"a":18446744073709551615}`, "a", uint64(1),
+			`{"a":18446744073709551616}`},
+		{`{"a":1.10}`, "a", 0.2, `{"a":1.30}`},
+		{`{"a":0.1}`, "a", 0.2, `{"a":0.3}`},
+		{`{"a":1}`, "a", 1.0, `{"a":2.0}`},
+		{`{"a":1.5}`, "a", 1, `{"a":2.5}`},
+		{`{"a":-0.05}`, "a", "0.01", `{"a":-0.04}`},
+		{`{"a":1}`, "a", jsongo.Number("12345678901234567890.5"),
+			`{"a":12345678901234567891.5}`},
+		{`{"a":1e3}`, "a", 1, `{"a":1001.0}`},
+		{`{"a":1}`, "a", "1e21", `{"a":1e+21}`},
+		{`{}`, "a.b", 5, `{"a":{"b":5}}`},
+		{`{}`, "a", 5.0, `{"a":5.0}`},
+		{`{"a":[1,2]}`, "a.#(>1)", 10, `{"a":[1,12]}`},
+		{`{"a":[1,2]}`, "a.#()#", 10, `{"a":[11,12]}`},
+	}
+	for i, tt := range tests {
+		json, err := Increment(tt.json, tt.path, tt.delta)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+	}
+	_, err := Increment(`{"a":"1"}`, "a", 1)
+	if !errors.Is(err, ErrNotNumber) {
+		t.Fatalf("expected ErrNotNumber, got %v", err)
+	}
+	_, err = Increment(`{"a":1}`, "a", "x")
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+	json, err := Increment(`{"a":[1,"x"]}`, "a.#()#", 1)
+	if !errors.Is(err, ErrNotNumber) || json != `{"a":[1,"x"]}` {
+		t.Fatalf("expected ErrNotNumber, got '%v', %v", json, err)
+	}
+	opts := &Options{Optimistic: true, ReplaceInPlace: true}
+	b, err := IncrementBytesOptions([]byte(`{"n":10}`), "n", -1, opts)
+	if err != nil || string(b) != `{"n":9}` {
+		t.Fatalf("unexpected result '%s', %v", b, err)
+	}
+}
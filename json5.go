@@ -0,0 +1,490 @@
+package sjson
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// json5Doc is a JSON5 document. The shadow is the document as json with
+// comments and trailing commas, which is edited the same way as JSONC.
+// Unquoted keys, single-quoted strings, hexadecimal numbers and the other
+// tokens that are not json are converted in the shadow, and as they don't
+// keep their length, the conversions are recorded to carry the edits over
+// to the source.
+type json5Doc struct {
+	src    string
+	shadow []byte
+	tokens []int // shadow start and end, source start and end of each token
+	// the number of quoted and unquoted keys, and of strings in single and
+	// double quotes, which decide the style of new keys and strings
+	quoted, unquoted int
+	single, double   int
+}
+
+// json5Space returns the length of the JSON5 whitespace at i that is not
+// json whitespace, or zero.
+func json5Space(src string, i int) int {
+	switch src[i] {
+	case '\v', '\f':
+		return 1
+	case 0xC2, 0xE2, 0xEF:
+		for _, sp := range []string{"\u00a0", "\u2028", "\u2029", "\ufeff"} {
+			if strings.HasPrefix(src[i:], sp) {
+				return len(sp)
+			}
+		}
+	}
+	return 0
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c == '\\' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isIdent returns true if s can be written as an unquoted key.
+func isIdent(s string) bool {
+	for i, r := range s {
+		if r != '_' && r != '$' && !unicode.IsLetter(r) &&
+			(i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// identEnd returns the index just after the identifier at i.
+func identEnd(src string, i int) int {
+	for i < len(src) {
+		if src[i] == '\\' {
+			i += 6
+			continue
+		}
+		if (!isIdentByte(src[i]) && !isDigit(src[i])) ||
+			json5Space(src, i) > 0 {
+			break
+		}
+		i++
+	}
+	if i > len(src) {
+		return len(src)
+	}
+	return i
+}
+
+// json5Number returns the index just after the number at i and the number as
+// json. NaN has no json equivalent and is null in the shadow, and Infinity
+// is a number that is too large for a float64.
+func json5Number(src string, i int) (int, string) {
+	j := i
+	var sign string
+	if src[j] == '+' || src[j] == '-' {
+		if src[j] == '-' {
+			sign = "-"
+		}
+		j++
+	}
+	switch {
+	case strings.HasPrefix(src[j:], "Infinity"):
+		return j + 8, sign + "1e999"
+	case strings.HasPrefix(src[j:], "NaN"):
+		return j + 3, "null"
+	case j+1 < len(src) && src[j] == '0' &&
+		(src[j+1] == 'x' || src[j+1] == 'X'):
+		k := j + 2
+		for k < len(src) && isHex(src[k]) {
+			k++
+		}
+		n, ok := new(big.Int).SetString(src[j+2:k], 16)
+		if !ok {
+			return k, src[i:k]
+		}
+		return k, sign + n.String()
+	}
+	k := j
+	for k < len(src) && isDigit(src[k]) {
+		k++
+	}
+	num := src[j:k]
+	var frac string
+	if k < len(src) && src[k] == '.' {
+		f := k + 1
+		for f < len(src) && isDigit(src[f]) {
+			f++
+		}
+		frac, k = src[k+1:f], f
+	}
+	if num == "" && frac == "" {
+		if k == i {
+			k++
+		}
+		return k, src[i:k]
+	}
+	if num == "" {
+		num = "0"
+	}
+	num = sign + num
+	if frac != "" {
+		num += "." + frac
+	}
+	if k < len(src) && (src[k] == 'e' || src[k] == 'E') {
+		f := k + 1
+		if f < len(src) && (src[f] == '+' || src[f] == '-') {
+			f++
+		}
+		for f < len(src) && isDigit(src[f]) {
+			f++
+		}
+		num, k = num+src[k:f], f
+	}
+	return k, num
+}
+
+// json5String returns the index just after the string at i, which may be in
+// single or double quotes, and the string it holds.
+func json5String(src string, i int) (int, string) {
+	q := src[i]
+	var buf []byte
+	for j := i + 1; j < len(src); j++ {
+		c := src[j]
+		if c == q {
+			return j + 1, string(buf)
+		}
+		if c != '\\' || j+1 == len(src) {
+			buf = append(buf, c)
+			continue
+		}
+		j++
+		switch c = src[j]; c {
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'v':
+			buf = append(buf, '\v')
+		case '0':
+			buf = append(buf, 0)
+		case 'x', 'u':
+			n := 2
+			if c == 'u' {
+				n = 4
+			}
+			if j+n >= len(src) {
+				buf = append(buf, c)
+				break
+			}
+			r, err := strconv.ParseUint(src[j+1:j+1+n], 16, 32)
+			if err != nil {
+				buf = append(buf, c)
+				break
+			}
+			j += n
+			rn := rune(r)
+			if utf16.IsSurrogate(rn) && j+6 < len(src) &&
+				src[j+1] == '\\' && src[j+2] == 'u' {
+				r2, err := strconv.ParseUint(src[j+3:j+7], 16, 32)
+				if dec := utf16.DecodeRune(rn, rune(r2)); err == nil &&
+					dec != unicode.ReplacementChar {
+					rn = dec
+					j += 6
+				}
+			}
+			buf = append(buf, string(rn)...)
+		case '\r':
+			// a line continuation
+			if j+1 < len(src) && src[j+1] == '\n' {
+				j++
+			}
+		case '\n':
+		default:
+			if strings.HasPrefix(src[j:], "\u2028") ||
+				strings.HasPrefix(src[j:], "\u2029") {
+				j += 2
+				break
+			}
+			buf = append(buf, c)
+		}
+	}
+	return len(src), string(buf)
+}
+
+func parseJSON5(src string) *json5Doc {
+	d := &json5Doc{src: src, shadow: make([]byte, 0, len(src)+16)}
+	for i := 0; i < len(src); {
+		c := src[i]
+		if n := json5Space(src, i); n > 0 {
+			d.convert(i, i+n, strings.Repeat(" ", n))
+			i += n
+			continue
+		}
+		switch {
+		case c == '"' || c == '\'':
+			end, str := json5String(src, i)
+			if c == '"' {
+				d.double++
+			} else {
+				d.single++
+			}
+			if d.isKey(end) {
+				d.quoted++
+			}
+			// strings are always recorded, so that edits never start or end
+			// inside of one
+			tok := src[i:end]
+			if j, msg := validString(src, i); c != '"' || msg != "" ||
+				j != end {
+				tok = string(appendStringify(nil, str))
+			}
+			d.convert(i, end, tok)
+			i = end
+		case c == '/':
+			end := commentEnd(src, i)
+			if end == i {
+				end++
+			}
+			d.shadow = append(d.shadow, src[i:end]...)
+			i = end
+		case c == '-' || c == '+' || c == '.' || isDigit(c):
+			end, num := json5Number(src, i)
+			if num != src[i:end] {
+				d.convert(i, end, num)
+			} else {
+				d.shadow = append(d.shadow, num...)
+			}
+			i = end
+		case isIdentByte(c):
+			end := identEnd(src, i)
+			ident := src[i:end]
+			switch {
+			case d.isKey(end):
+				d.unquoted++
+				key := ident
+				if strings.IndexByte(key, '\\') != -1 {
+					key = gjson.Parse(`"` + key + `"`).Str
+				}
+				d.convert(i, end, string(appendStringify(nil, key)))
+			case ident == "Infinity" || ident == "NaN":
+				_, num := json5Number(src, i)
+				d.convert(i, end, num)
+			default:
+				d.shadow = append(d.shadow, ident...)
+			}
+			i = end
+		default:
+			d.shadow = append(d.shadow, c)
+			i++
+		}
+	}
+	return d
+}
+
+// isKey returns true if the token that ends at i is followed by a colon.
+func (d *json5Doc) isKey(i int) bool {
+	i = skipJSONC(d.src, i)
+	return i < len(d.src) && d.src[i] == ':'
+}
+
+func (d *json5Doc) convert(start, end int, tok string) {
+	d.tokens = append(d.tokens, len(d.shadow), len(d.shadow)+len(tok),
+		start, end)
+	d.shadow = append(d.shadow, tok...)
+}
+
+// token returns the index of the converted token that ends after the
+// shadow position i, and whether i is inside of it.
+func (d *json5Doc) token(i int) (int, bool) {
+	n := sort.Search(len(d.tokens)/4, func(j int) bool {
+		return d.tokens[j*4+1] > i
+	})
+	return n, n < len(d.tokens)/4 && d.tokens[n*4] < i
+}
+
+// pos returns the source position for the shadow position i, which must not
+// be inside of a converted token.
+func (d *json5Doc) pos(i int) int {
+	n, _ := d.token(i)
+	if n == 0 {
+		return i
+	}
+	return i + d.tokens[n*4-1] - d.tokens[n*4-3]
+}
+
+// splice carries the edit that turned the shadow into res over to the
+// source. The converted tokens are kept whole, and the keys and strings in
+// the edit are written in the style of the document. The range of rr, when
+// it's not nil, is mapped to the new source.
+func (d *json5Doc) splice(res []byte, rr *Result) []byte {
+	s, tokens := d.shadow, d.tokens
+	n := len(s)
+	if len(res) < n {
+		n = len(res)
+	}
+	var p, sfx int
+	for p < n && s[p] == res[p] {
+		p++
+	}
+	// a number that the edit makes longer is replaced as a whole
+	if k, ok := d.token(p); ok {
+		p = tokens[k*4]
+	} else if k > 0 && tokens[k*4-3] == p && p < len(res) &&
+		isNumberByte(res[p]) {
+		p = tokens[k*4-4]
+	}
+	for sfx < n-p && s[len(s)-1-sfx] == res[len(res)-1-sfx] {
+		sfx++
+	}
+	e, r := len(s)-sfx, len(res)-sfx
+	if k, ok := d.token(e); ok {
+		e = tokens[k*4+1]
+	} else if k < len(tokens)/4 && tokens[k*4] == e && r > p &&
+		isNumberByte(res[r-1]) {
+		e = tokens[k*4+1]
+	}
+	r = len(res) - (len(s) - e)
+	json := *(*string)(unsafe.Pointer(&res))
+	buf := make([]byte, 0, len(d.src)+r-p+1)
+	buf = append(buf, d.src[:d.pos(p)]...)
+	buf = d.appendStyled(buf, json, p, r)
+	if rr != nil && rr.Matches > 0 {
+		start := d.pos(p)
+		mid := len(buf) - start
+		move := func(i int) int {
+			switch {
+			case i <= p:
+				return d.pos(i)
+			case i <= r:
+				return start + len(d.appendStyled(nil, json, p, i))
+			}
+			return start + mid + d.pos(e+i-r) - d.pos(e)
+		}
+		rr.Start, rr.End = move(rr.Start), move(rr.End)
+	}
+	return append(buf, d.src[d.pos(e):]...)
+}
+
+func isNumberByte(c byte) bool {
+	return isDigit(c) || c == '.' || c == 'e' || c == 'E' || c == '+' ||
+		c == '-'
+}
+
+// appendStyled appends json[i:end], which is part of the edited json. Keys
+// are unquoted when they are identifiers, unless the document mostly quotes
+// its keys, and strings are in single quotes when the document mostly uses
+// them.
+func (d *json5Doc) appendStyled(buf []byte, json string, i, end int) []byte {
+	unquoted := d.unquoted >= d.quoted
+	single := d.single > d.double
+	for i < end {
+		switch json[i] {
+		case '"':
+			raw := json[i:valueEnd(json, i)]
+			i += len(raw)
+			if unquoted {
+				j := skipJSONC(json, i)
+				if j < len(json) && json[j] == ':' {
+					if key := gjson.Parse(raw).Str; isIdent(key) {
+						buf = append(buf, key...)
+						continue
+					}
+				}
+			}
+			if !single {
+				buf = append(buf, raw...)
+				continue
+			}
+			buf = append(buf, '\'')
+			for j := 1; j < len(raw)-1; j++ {
+				switch raw[j] {
+				case '\\':
+					if raw[j+1] != '"' {
+						buf = append(buf, '\\')
+					}
+					j++
+					buf = append(buf, raw[j])
+				case '\'':
+					buf = append(buf, '\\', '\'')
+				default:
+					buf = append(buf, raw[j])
+				}
+			}
+			buf = append(buf, '\'')
+		case '/':
+			j := commentEnd(json, i)
+			if j == i {
+				j++
+			}
+			buf = append(buf, json[i:j]...)
+			i = j
+		default:
+			buf = append(buf, json[i])
+			i++
+		}
+	}
+	return buf
+}
+
+// set performs the operation on the shadow as JSONC.
+func (d *json5Doc) set(path string, op *setOp, opts *Options) ([]byte,
+	error) {
+	return set(*(*string)(unsafe.Pointer(&d.shadow)), path, op, opts)
+}
+
+// setJSON5 performs the operation on a JSON5 document. Like JSONC, paths
+// that match multiple values are split into a simple path for each match.
+func setJSON5(jstr, path string, op *setOp, opts *Options) ([]byte, error) {
+	nopts := *opts
+	nopts.JSON5, nopts.JSONC = false, true
+	d := parseJSON5(jstr)
+	inner := *op
+	inner.dst, inner.capacity, inner.appending = nil, 0, false
+	res, err := d.set(path, &inner, &nopts)
+	op.res = inner.res
+	if err != nil {
+		return []byte(jstr), err
+	}
+	var paths []string
+	if !nopts.Pointer && !op.insert {
+		if _, simple := parseSimplePath(path); !simple {
+			sstr := string(parseJSONC(string(d.shadow)).shadow)
+			paths = gjson.Get(sstr, path).Paths(sstr)
+		}
+	}
+	if len(paths) <= 1 {
+		return append(op.dst, d.splice(res, &op.res)...), nil
+	}
+	nopts.Upsert = false
+	op.res = Result{}
+	for i := len(paths) - 1; i >= 0; i-- {
+		inner := *op
+		inner.dst, inner.capacity, inner.appending = nil, 0, false
+		res, err := d.set(paths[i], &inner, &nopts)
+		if err == errNoChange {
+			if inner.res.Matches > 0 {
+				inner.res.Start = d.pos(inner.res.Start)
+				inner.res.End = d.pos(inner.res.End)
+			}
+			op.recordResult(inner.res, 0)
+			continue
+		}
+		if err != nil {
+			return []byte(jstr), err
+		}
+		n := len(d.src)
+		d = parseJSON5(string(d.splice(res, &inner.res)))
+		op.recordResult(inner.res, len(d.src)-n)
+	}
+	return append(op.dst, d.src...), nil
+}
@@ -0,0 +1,218 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJSON5(t *testing.T) {
+	json := `{
+  // the server
+  name: 'web',
+  port: 0x50,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['a', 'b',],
+  "quoted-key": 'it\'s',
+}
+`
+	opts := &Options{JSON5: true}
+	indent := &Options{JSON5: true, MatchIndent: true}
+	tests := []struct {
+		path   string
+		value  interface{}
+		opts   *Options
+		expect string
+	}{
+		{"port", 8080, opts, `{
+  // the server
+  name: 'web',
+  port: 8080,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['a', 'b',],
+  "quoted-key": 'it\'s',
+}
+`},
+		{"name", "api", opts, `{
+  // the server
+  name: 'api',
+  port: 0x50,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['a', 'b',],
+  "quoted-key": 'it\'s',
+}
+`},
+		{"tls.enabled", true, indent, `{
+  // the server
+  name: 'web',
+  port: 0x50,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['a', 'b',],
+  "quoted-key": 'it\'s',
+  tls: {
+    enabled: true
+  },
+}
+`},
+		{"new key", "it's", indent, `{
+  // the server
+  name: 'web',
+  port: 0x50,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['a', 'b',],
+  "quoted-key": 'it\'s',
+  'new key': 'it\'s',
+}
+`},
+		{"tags.-1", "c", opts, `{
+  // the server
+  name: 'web',
+  port: 0x50,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['a', 'b','c',],
+  "quoted-key": 'it\'s',
+}
+`},
+		{"ratio", dtype{}, opts, `{
+  // the server
+  name: 'web',
+  port: 0x50,
+  max: +Infinity,
+  tags: ['a', 'b',],
+  "quoted-key": 'it\'s',
+}
+`},
+		{"quoted-key", `say "hi"`, opts, `{
+  // the server
+  name: 'web',
+  port: 0x50,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['a', 'b',],
+  "quoted-key": 'say "hi"',
+}
+`},
+		{"tags.#(!=\"\")#", "x", opts, `{
+  // the server
+  name: 'web',
+  port: 0x50,
+  ratio: .5,
+  max: +Infinity,
+  tags: ['x', 'x',],
+  "quoted-key": 'it\'s',
+}
+`},
+	}
+	for i, tt := range tests {
+		res, err := SetOptions(json, tt.path, tt.value, tt.opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+	}
+}
+
+func TestJSON5Tokens(t *testing.T) {
+	opts := &Options{JSON5: true}
+	tests := []struct {
+		json, path string
+		value      interface{}
+		expect     string
+	}{
+		{`{"a": 1}`, "b", 2, `{"a": 1,"b":2}`},
+		{``, "a.b", "c", `{a:{b:"c"}}`},
+		{`{a: NaN, b: -Infinity, c: 0xFF}`, "c", 1,
+			`{a: NaN, b: -Infinity, c: 1}`},
+		{`{a: NaN, b: -Infinity, c: 0xFF}`, "d", 1,
+			`{a: NaN, b: -Infinity, c: 0xFF,d:1}`},
+		{`{a: 0x10, b: 5.}`, "a", 1016, `{a: 1016, b: 5.}`},
+		{`{a: 0x10, b: 5.}`, "b", 6, `{a: 0x10, b: 6}`},
+		{`{a: 'x\
+y', b: 1}`, "b", 2, `{a: 'x\
+y', b: 2}`},
+		{`{ab: 1,` + "\v" + `c: 2}`, "ab", dtype{}, "{c: 2}"},
+		{`{a: 1}`, "a-b", 2, `{a: 1,"a-b":2}`},
+		{`['a', "b"]`, "-1", "c", `['a', "b","c"]`},
+		{`{a: [1, 2,],}`, "a.0", dtype{}, `{a: [2,],}`},
+		{`{list: [0x10, Infinity, NaN, .5, +1,]}`, "list.8", 1,
+			`{list: [0x10, Infinity, NaN, .5, +1,null,null,null,1,]}`},
+		{`{list: [0x10, -Infinity, NaN]}`, "list.4.a", "b",
+			`{list: [0x10, -Infinity, NaN,null,{a:"b"}]}`},
+		{`[NaN, 0xff]`, "2", 1, `[NaN, 0xff,1]`},
+		{"// header\n{\n  a: 1,\n}\n", "b", 2,
+			"// header\n{\n  a: 1,\nb:2,}\n"},
+	}
+	for i, tt := range tests {
+		res, err := SetOptions(tt.json, tt.path, tt.value, opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+	}
+}
+
+func TestJSON5Result(t *testing.T) {
+	opts := &Options{JSON5: true}
+	tests := []struct {
+		json, path string
+		value      interface{}
+		expect     string
+		span       string
+		matches    int
+	}{
+		{"{name:'abc', x:1}", "x", 2, "{name:'abc', x:2}", `2`, 1},
+		{"{name:'abc', x:1}", "y", 3, "{name:'abc', x:1,y:3}",
+			`,y:3`, 1},
+		{"{name:'abc', 'y':[0x1]}", "y.2", "s",
+			"{name:'abc', 'y':[0x1,null,'s']}", `'s'`, 1},
+		{"{a:'x', b:[1,2,1,],}", "b.#(==1)#", "it's",
+			"{a:'x', b:['it\\'s',2,'it\\'s',],}", `'it\'s',2,'it\'s'`, 2},
+		{"{a:'abc', b:1}", "a", dtype{}, "{b:1}", ``, 1},
+	}
+	for i, tt := range tests {
+		json, res, err := SetWithResult(tt.json, tt.path, tt.value, opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+		if res.Matches != tt.matches || res.Start > res.End ||
+			res.End > len(json) || json[res.Start:res.End] != tt.span {
+			t.Fatalf("test %d: unexpected result %+v", i, res)
+		}
+	}
+}
+
+func TestJSON5Options(t *testing.T) {
+	json := []byte("{a: 'x' /* one */}")
+	res, err := SetBytesOptions(json, "a", "y",
+		&Options{JSON5: true, ReplaceInPlace: true})
+	if err != nil || string(res) != "{a: 'y' /* one */}" {
+		t.Fatalf("unexpected result '%s', %v", res, err)
+	}
+	_, err = SetOptions("{a: 1", "a", 2, &Options{JSON5: true, Strict: true})
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("expected ErrInvalidJSON, got %v", err)
+	}
+	p, _ := CompilePath("a.b")
+	res, err = SetBytesPathOptions([]byte("{a: {b: 0x1}}"), p, 2,
+		&Options{JSON5: true})
+	if err != nil || string(res) != "{a: {b: 2}}" {
+		t.Fatalf("unexpected result '%s', %v", res, err)
+	}
+	res, err = SetBytesOptions([]byte("{a: 1}"), "/b", 2,
+		&Options{JSON5: true, Pointer: true})
+	if err != nil || string(res) != "{a: 1,b:2}" {
+		t.Fatalf("unexpected result '%s', %v", res, err)
+	}
+}
@@ -0,0 +1,443 @@
+package sjson
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// jsoncDoc is a json document that may have comments and trailing commas.
+// The shadow has the same length as the source, with the comments and
+// trailing commas replaced by spaces, so that it can be edited as plain json
+// and the edits can be carried over to the source at the same positions.
+type jsoncDoc struct {
+	src      string
+	shadow   []byte
+	comments []int // start and end index of each comment
+	commas   []int // index of each trailing comma
+}
+
+// commentEnd returns the index just after the comment at i, or i when there
+// is no comment at i. A line comment ends before the newline.
+func commentEnd(src string, i int) int {
+	if i+1 >= len(src) || src[i] != '/' {
+		return i
+	}
+	switch src[i+1] {
+	case '/':
+		for j := i + 2; j < len(src); j++ {
+			if src[j] == '\n' {
+				return j
+			}
+		}
+		return len(src)
+	case '*':
+		for j := i + 2; j < len(src)-1; j++ {
+			if src[j] == '*' && src[j+1] == '/' {
+				return j + 2
+			}
+		}
+		return len(src)
+	}
+	return i
+}
+
+// skipJSONC returns the index of the first character at or after i that is
+// not whitespace or part of a comment.
+func skipJSONC(src string, i int) int {
+	for i < len(src) {
+		switch src[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+			continue
+		}
+		end := commentEnd(src, i)
+		if end == i {
+			break
+		}
+		i = end
+	}
+	return i
+}
+
+func parseJSONC(src string) *jsoncDoc {
+	d := &jsoncDoc{src: src, shadow: []byte(src)}
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			i = valueEnd(src, i) - 1
+		case '/':
+			end := commentEnd(src, i)
+			if end == i {
+				continue
+			}
+			d.comments = append(d.comments, i, end)
+			for j := i; j < end; j++ {
+				if src[j] != '\n' && src[j] != '\r' {
+					d.shadow[j] = ' '
+				}
+			}
+			i = end - 1
+		case ',':
+			j := skipJSONC(src, i+1)
+			if j < len(src) && (src[j] == '}' || src[j] == ']') {
+				d.commas = append(d.commas, i)
+				d.shadow[i] = ' '
+			}
+		}
+	}
+	return d
+}
+
+// comment returns the comment that contains i, not counting its first
+// character.
+func (d *jsoncDoc) comment(i int) (start, end int, ok bool) {
+	n := sort.Search(len(d.comments)/2, func(j int) bool {
+		return d.comments[j*2] >= i
+	})
+	if n > 0 && d.comments[n*2-1] > i {
+		return d.comments[n*2-2], d.comments[n*2-1], true
+	}
+	return 0, 0, false
+}
+
+// hasComment returns true if a comment starts in src[start:end].
+func (d *jsoncDoc) hasComment(start, end int) bool {
+	n := sort.Search(len(d.comments)/2, func(j int) bool {
+		return d.comments[j*2] >= start
+	})
+	return n < len(d.comments)/2 && d.comments[n*2] < end
+}
+
+// hasComma returns true if there is a trailing comma in src[start:end].
+func (d *jsoncDoc) hasComma(start, end int) bool {
+	n := sort.SearchInts(d.commas, start)
+	return n < len(d.commas) && d.commas[n] < end
+}
+
+// lineEnd returns the index after the whitespace, comments and trailing
+// commas that follow i on the same line. A comment that starts on the line
+// is skipped entirely, even when it continues on the next lines.
+func (d *jsoncDoc) lineEnd(i int) int {
+	for i < len(d.src) {
+		switch d.src[i] {
+		case ' ', '\t', '\r':
+			i++
+			continue
+		case ',':
+			if d.hasComma(i, i+1) {
+				i++
+				continue
+			}
+		}
+		end := commentEnd(d.src, i)
+		if end == i {
+			break
+		}
+		i = end
+	}
+	return i
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// splice carries the edit that turned the shadow into res over to the
+// source. The edit must be a single change, such as the one made by a set
+// on a simple path. The range of r, when it's not nil, is moved along with
+// the edit.
+func (d *jsoncDoc) splice(res []byte, del bool, r *Result) []byte {
+	src, s := d.src, d.shadow
+	n := len(s)
+	if len(res) < n {
+		n = len(res)
+	}
+	var p, sfx int
+	for p < n && s[p] == res[p] {
+		p++
+	}
+	for sfx < n-p && s[len(s)-1-sfx] == res[len(res)-1-sfx] {
+		sfx++
+	}
+	// keep comments whole
+	if start, _, ok := d.comment(p); ok {
+		p = start
+	}
+	if _, end, ok := d.comment(len(s) - sfx); ok {
+		sfx = len(s) - end
+	}
+	e := len(s) - sfx
+	mid := res[p : len(res)-sfx]
+	if del && len(trim(string(mid))) == 0 && e > p {
+		out, at := d.delete(p, e)
+		if r != nil && r.Matches > 0 {
+			r.Start, r.End = at, at
+		}
+		return out
+	}
+	buf := make([]byte, 0, len(src)+len(mid)+1)
+	if p == e && len(mid) > 0 && mid[0] == ',' {
+		// a member added after the last member
+		v := p
+		for v > 0 && isBlank(s[v-1]) {
+			v--
+		}
+		t := d.lineEnd(p)
+		if d.hasComma(v, t) {
+			// the new member goes after the trailing comma and gets a
+			// trailing comma of its own
+			buf = append(buf, src[:t]...)
+			buf = append(buf, mid[1:]...)
+			buf = append(buf, ',')
+			moveResult(r, p, len(mid), t)
+			return append(buf, src[t:]...)
+		}
+		if d.hasComment(v, t) {
+			// the new member goes after the comments on the line of the
+			// last member, but the comma stays with the last member
+			buf = append(buf, src[:v]...)
+			buf = append(buf, ',')
+			buf = append(buf, src[v:t]...)
+			buf = append(buf, mid[1:]...)
+			moveResult(r, p, len(mid), t+1)
+			return append(buf, src[t:]...)
+		}
+	}
+	buf = append(buf, src[:p]...)
+	buf = append(buf, mid...)
+	return append(buf, src[e:]...)
+}
+
+// moveResult moves the range of r from the member that was added at p in
+// the shadow, as the n bytes of res[p:p+n] that start with a comma, to the
+// source position at where the member is written without its comma.
+func moveResult(r *Result, p, n, at int) {
+	if r == nil || r.Matches == 0 {
+		return
+	}
+	move := func(i int) int {
+		if i < p || i > p+n {
+			return i
+		}
+		if i == p {
+			i++
+		}
+		return at + i - p - 1
+	}
+	r.Start, r.End = move(r.Start), move(r.End)
+}
+
+// delete removes the member or element in src[start:end], which is the span
+// removed from the shadow, and returns the position where it was removed. A
+// member on a line of its own is removed along with its line and the
+// comment that follows it, and other comments are kept.
+func (d *jsoncDoc) delete(start, end int) ([]byte, int) {
+	src, s := d.src, d.shadow
+	// find the key, or element, and the end of the value
+	ks, ve := start, end
+	for ks < ve && (isBlank(s[ks]) || s[ks] == ',') {
+		ks++
+	}
+	for ve > ks && (isBlank(s[ve-1]) || s[ve-1] == ',') {
+		ve--
+	}
+	// the comma after the value, which is -1 for the last member
+	comma := skipJSONC(src, ve)
+	if comma == len(src) || src[comma] != ',' {
+		comma = -1
+	}
+	trailing := comma != -1 && d.hasComma(comma, comma+1)
+	// the comma before the member, which is -1 for the first member
+	prev := ks - 1
+	for prev >= 0 && isBlank(s[prev]) {
+		prev--
+	}
+	if prev >= 0 && s[prev] != ',' {
+		prev = -1
+	}
+	ls := ks
+	for ls > 0 && (src[ls-1] == ' ' || src[ls-1] == '\t') {
+		ls--
+	}
+	if ls > 0 && src[ls-1] == '\n' {
+		le := ve
+		if comma != -1 {
+			le = comma + 1
+		}
+		le = d.lineEnd(le)
+		if le < len(src) && src[le] == '\n' {
+			// the member is on a line of its own
+			buf := make([]byte, 0, len(src))
+			if comma == -1 && prev != -1 {
+				buf = append(buf, src[:prev]...)
+				buf = append(buf, src[prev+1:ls]...)
+			} else {
+				buf = append(buf, src[:ls]...)
+			}
+			at := len(buf)
+			return append(buf, src[le+1:]...), at
+		}
+	}
+	buf := make([]byte, 0, len(src))
+	switch {
+	case trailing:
+		// the member before, if any, keeps its comma as a trailing comma
+		buf = append(buf, src[:ls]...)
+		return append(buf, src[comma+1:]...), ls
+	case comma != -1:
+		i := comma + 1
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+			i++
+		}
+		buf = append(buf, src[:ks]...)
+		return append(buf, src[i:]...), ks
+	case prev != -1:
+		buf = append(buf, src[:prev]...)
+		buf = append(buf, src[prev+1:ls]...)
+		return append(buf, src[ve:]...), ls - 1
+	}
+	buf = append(buf, src[:ks]...)
+	return append(buf, src[ve:]...), ks
+}
+
+// set performs the operation on the shadow. The whitespace before and after
+// the root value is left out, as adding a member to the root would drop it,
+// along with the comments that it stands in for.
+func (d *jsoncDoc) set(path string, op *setOp, opts *Options) ([]byte,
+	error) {
+	start, end := 0, len(d.shadow)
+	for start < end && isBlank(d.shadow[start]) {
+		start++
+	}
+	for end > start && isBlank(d.shadow[end-1]) {
+		end--
+	}
+	shadow := d.shadow[start:end]
+	res, err := set(*(*string)(unsafe.Pointer(&shadow)), path, op, opts)
+	if op.res.Matches > 0 {
+		op.res.Start += start
+		op.res.End += start
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, start+len(res)+len(d.shadow)-end)
+	buf = append(buf, d.shadow[:start]...)
+	buf = append(buf, res...)
+	return append(buf, d.shadow[end:]...), nil
+}
+
+// pad returns the document with the array that the path indexes past its end
+// padded with nulls up to the index, or nil when the path doesn't do that.
+// sjson rebuilds an array that it pads, which would drop its comments and
+// trailing commas, so the nulls are added as new elements instead.
+func (d *jsoncDoc) pad(path string, pointer bool) *jsoncDoc {
+	var paths []pathResult
+	var ok bool
+	if pointer {
+		var err error
+		paths, err = parsePointer(path)
+		ok = err == nil
+	} else {
+		paths, ok = parseSimplePath(path)
+	}
+	if !ok {
+		return nil
+	}
+	raw := string(d.shadow)
+	var parent string
+	for _, p := range paths {
+		if res := gjson.Get(raw, p.gpart); res.Exists() {
+			raw = res.Raw
+			parent += p.gpart + "."
+			continue
+		}
+		n, err := strconv.Atoi(p.part)
+		if p.force || err != nil || n < 0 || !isArray(raw) {
+			return nil
+		}
+		count := int(gjson.Get(raw, "#").Int())
+		if n < count {
+			return nil
+		}
+		nulls := strings.Repeat("null,", n-count) + "null"
+		res, err := d.set(parent+"-1", &setOp{raw: nulls}, nil)
+		if err != nil {
+			return nil
+		}
+		return parseJSONC(string(d.splice(res, false, nil)))
+	}
+	return nil
+}
+
+// setJSONC performs the operation on json with comments and trailing commas.
+// Paths that match multiple values are split into a simple path for each
+// match, so that every edit can be carried over from the shadow.
+func setJSONC(jstr, path string, op *setOp, opts *Options) ([]byte, error) {
+	nopts := *opts
+	nopts.JSONC = false
+	d := parseJSONC(jstr)
+	inner := *op
+	inner.dst, inner.capacity, inner.appending = nil, 0, false
+	res, err := d.set(path, &inner, &nopts)
+	op.res = inner.res
+	if err != nil {
+		return []byte(jstr), err
+	}
+	if !op.del && !op.insert && op.update == nil && !nopts.MatchIndent {
+		// the operation succeeded, so it's safe to pad the array and then
+		// set the value on the null at the index
+		if pd := d.pad(path, nopts.Pointer); pd != nil {
+			nopts.OnlyIfAbsent = false
+			inner := *op
+			inner.dst, inner.capacity, inner.appending = nil, 0, false
+			inner.res = Result{}
+			res, err := pd.set(path, &inner, &nopts)
+			if err == errNoChange {
+				// the nulls that were added are still a change
+				op.res = inner.res
+				op.res.Changed = true
+				return append(op.dst, pd.src...), nil
+			}
+			if err != nil {
+				return []byte(jstr), err
+			}
+			op.res = inner.res
+			return append(op.dst, pd.splice(res, false, &op.res)...), nil
+		}
+	}
+	var paths []string
+	if !nopts.Pointer && !op.insert {
+		if _, simple := parseSimplePath(path); !simple {
+			sstr := *(*string)(unsafe.Pointer(&d.shadow))
+			paths = gjson.Get(sstr, path).Paths(sstr)
+		}
+	}
+	if len(paths) <= 1 {
+		return append(op.dst, d.splice(res, op.del, &op.res)...), nil
+	}
+	// apply the edits from the end of the document, so that the earlier
+	// paths are not affected
+	nopts.Upsert = false
+	op.res = Result{}
+	for i := len(paths) - 1; i >= 0; i-- {
+		inner := *op
+		inner.dst, inner.capacity, inner.appending = nil, 0, false
+		res, err := d.set(paths[i], &inner, &nopts)
+		if err == errNoChange {
+			op.recordResult(inner.res, 0)
+			continue
+		}
+		if err != nil {
+			return []byte(jstr), err
+		}
+		n := len(d.src)
+		d = parseJSONC(string(d.splice(res, op.del, &inner.res)))
+		op.recordResult(inner.res, len(d.src)-n)
+	}
+	return append(op.dst, d.src...), nil
+}
@@ -0,0 +1,186 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJSONC(t *testing.T) {
+	json := `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 80, /* the port */
+  "tags": ["a", "b",],
+  "tls": {"enabled": false} // off for now
+}
+`
+	opts := &Options{JSONC: true}
+	indent := &Options{JSONC: true, MatchIndent: true}
+	tests := []struct {
+		path   string
+		value  interface{}
+		opts   *Options
+		expect string
+	}{
+		{"port", 8080, opts, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 8080, /* the port */
+  "tags": ["a", "b",],
+  "tls": {"enabled": false} // off for now
+}
+`},
+		{"name", "web", indent, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 80, /* the port */
+  "tags": ["a", "b",],
+  "tls": {"enabled": false}, // off for now
+  "name": "web"
+}
+`},
+		{"tags.-1", "c", opts, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 80, /* the port */
+  "tags": ["a", "b","c",],
+  "tls": {"enabled": false} // off for now
+}
+`},
+		{"port", dtype{}, opts, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "tags": ["a", "b",],
+  "tls": {"enabled": false} // off for now
+}
+`},
+		{"tls", dtype{}, opts, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 80, /* the port */
+  "tags": ["a", "b",]
+}
+`},
+		{"tags.1", dtype{}, opts, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 80, /* the port */
+  "tags": ["a",],
+  "tls": {"enabled": false} // off for now
+}
+`},
+		{"tags.#(!=\"\")#", "x", opts, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 80, /* the port */
+  "tags": ["x", "x",],
+  "tls": {"enabled": false} // off for now
+}
+`},
+		{"tls.enabled", true, opts, `{
+  // the server
+  "url": "http://example.com", // not a comment
+  "port": 80, /* the port */
+  "tags": ["a", "b",],
+  "tls": {"enabled": true} // off for now
+}
+`},
+	}
+	for i, tt := range tests {
+		res, err := SetOptions(json, tt.path, tt.value, tt.opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+	}
+}
+
+func TestJSONCTrailingCommas(t *testing.T) {
+	opts := &Options{JSONC: true}
+	tests := []struct {
+		json, path string
+		value      interface{}
+		expect     string
+	}{
+		{`{"a": 1,}`, "b", 2, `{"a": 1,"b":2,}`},
+		{`{"a": 1,}`, "a", dtype{}, `{}`},
+		{`[1, 2, 3,]`, "-1", dtype{}, `[1, 2,]`},
+		{`[1, 2, 3,]`, "0", dtype{}, `[2, 3,]`},
+		{"[\n  1,\n  2, // two\n]", "1", dtype{}, "[\n  1,\n]"},
+		{"{\n  \"a\": 1, /* one */\n  \"b\": 2\n}", "b", dtype{},
+			"{\n  \"a\": 1 /* one */\n}"},
+		{"// Place your settings in this file\n{\n  \"a\": 1\n}\n", "b", 2,
+			"// Place your settings in this file\n{\n  \"a\": 1\n,\"b\":2}\n"},
+		{"/* header */ {\"a\": 1}", "a", dtype{}, "/* header */ {}"},
+		{`[1, /* two */ 2,]`, "3", 4, `[1, /* two */ 2,null,4,]`},
+		{"{\"a\": {\"b\": 2 // tail b\n}}", "a.c", 3,
+			"{\"a\": {\"b\": 2, // tail b\n\"c\":3}}"},
+	}
+	for i, tt := range tests {
+		res, err := SetOptions(tt.json, tt.path, tt.value, opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+	}
+}
+
+func TestJSONCResult(t *testing.T) {
+	opts := &Options{JSONC: true}
+	tests := []struct {
+		json, path string
+		value      interface{}
+		expect     string
+		span       string
+		matches    int
+	}{
+		{"{\"a\":1, // c\n}", "b", 2, "{\"a\":1, // c\n\"b\":2,}",
+			`"b":2`, 1},
+		{"{\"a\":1 // c\n}", "b", 2, "{\"a\":1, // c\n\"b\":2}",
+			`"b":2`, 1},
+		{"// c\n{\"a\":1}", "a", 2, "// c\n{\"a\":2}", `2`, 1},
+		{"{\"a\":[1], // c\n}", "a.3", 2,
+			"{\"a\":[1,null,null,2], // c\n}", `2`, 1},
+		{"{\"a\":[1,2,1], // c\n}", "a.#(==1)#", 10,
+			"{\"a\":[10,2,10], // c\n}", `10,2,10`, 2},
+		{"{\"a\":1, /* x */ \"b\":2}", "a", dtype{}, "{/* x */ \"b\":2}",
+			``, 1},
+	}
+	for i, tt := range tests {
+		json, res, err := SetWithResult(tt.json, tt.path, tt.value, opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+		if res.Matches != tt.matches || res.Start > res.End ||
+			res.End > len(json) || json[res.Start:res.End] != tt.span {
+			t.Fatalf("test %d: unexpected result %+v", i, res)
+		}
+	}
+}
+
+func TestJSONCOptions(t *testing.T) {
+	json := []byte("{\"a\": 1 /* one */}")
+	res, err := SetBytesOptions(json, "a", 2,
+		&Options{JSONC: true, ReplaceInPlace: true})
+	if err != nil || string(res) != "{\"a\": 2 /* one */}" {
+		t.Fatalf("unexpected result '%s', %v", res, err)
+	}
+	_, err = SetOptions("{\"a\": 1 // one\n", "a", 2,
+		&Options{JSONC: true, Strict: true})
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("expected ErrInvalidJSON, got %v", err)
+	}
+	p, _ := CompilePath("a")
+	res, err = SetBytesPathOptions([]byte("{\"a\": 1 // one\n}"), p, 2,
+		&Options{JSONC: true})
+	if err != nil || string(res) != "{\"a\": 2 // one\n}" {
+		t.Fatalf("unexpected result '%s', %v", res, err)
+	}
+}
@@ -0,0 +1,203 @@
+package sjson
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// linesBatchSize is the number of lines that are edited together by a
+// worker.
+const linesBatchSize = 256
+
+// LinesOptions are the options for EditLines.
+type LinesOptions struct {
+	// Filter is a gjson query condition, such as `level=="error"` or
+	// `status>=500`, that a line must match to be edited. Lines that do not
+	// match are written unchanged. An empty Filter matches every line.
+	Filter string
+	// Workers is the number of goroutines that edit lines. The default is
+	// runtime.GOMAXPROCS(0).
+	Workers int
+}
+
+// LineError is returned by EditLines when a line could not be edited.
+type LineError struct {
+	Line int   // line number, starting at 1
+	Err  error // reason for the error
+}
+
+func (err *LineError) Error() string {
+	return "line " + strconv.Itoa(err.Line) + ": " + err.Err.Error()
+}
+
+// Unwrap returns the reason for the error.
+func (err *LineError) Unwrap() error {
+	return err.Err
+}
+
+// lineBatch is a group of lines that are edited by a worker. The done
+// channel is closed when out or err is ready.
+type lineBatch struct {
+	line  int // line number of the first line
+	lines [][]byte
+	out   []byte
+	err   error
+	done  chan struct{}
+}
+
+// edit edits each line of the batch and collects the output. The output
+// stops at the first line that can't be edited.
+func (b *lineBatch) edit(ops []Op, filter string) {
+	defer close(b.done)
+	for i, line := range b.lines {
+		body, end := splitLine(line)
+		if len(bytes.TrimSpace(body)) == 0 ||
+			(filter != "" && !matchLine(body, filter)) {
+			b.out = append(b.out, line...)
+			continue
+		}
+		res, err := SetManyBytes(body, ops)
+		if err != nil {
+			b.err = &LineError{Line: b.line + i, Err: err}
+			return
+		}
+		b.out = append(b.out, res...)
+		b.out = append(b.out, end...)
+	}
+}
+
+// splitLine separates a line from its line ending.
+func splitLine(line []byte) (body, end []byte) {
+	n := len(line)
+	if n > 0 && line[n-1] == '\n' {
+		n--
+		if n > 0 && line[n-1] == '\r' {
+			n--
+		}
+	}
+	return line[:n], line[n:]
+}
+
+// matchLine returns true if the json in line matches the gjson condition.
+func matchLine(line []byte, filter string) bool {
+	json := make([]byte, 0, len(line)+2)
+	json = append(json, '[')
+	json = append(json, line...)
+	json = append(json, ']')
+	return gjson.GetBytes(json, "#("+filter+")").Exists()
+}
+
+// EditLines applies the operations to every line of a newline-delimited
+// json stream, such as NDJSON or JSON Lines, and writes the result to w.
+// Each line is edited like SetMany. Empty lines and line endings are kept
+// as they are.
+//
+//	err := sjson.EditLines(w, r, []sjson.Op{
+//		{Kind: sjson.OpDelete, Path: "user.password"},
+//	}, &sjson.LinesOptions{Filter: `level=="error"`})
+//
+// Lines are edited in parallel by multiple goroutines, and are written in
+// the same order that they are read. When a line can't be edited a
+// *LineError is returned, and only the lines before it are written. The
+// opts may be nil.
+func EditLines(w io.Writer, r io.Reader, ops []Op, opts *LinesOptions) error {
+	var filter string
+	workers := runtime.GOMAXPROCS(0)
+	if opts != nil {
+		filter = opts.Filter
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+	}
+	jobs := make(chan *lineBatch, workers)
+	order := make(chan *lineBatch, workers*2)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				b.edit(ops, filter)
+			}
+		}()
+	}
+	var rerr error
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		rd := bufio.NewReader(r)
+		line := 1
+		for {
+			b := &lineBatch{line: line, done: make(chan struct{})}
+			var err error
+			for len(b.lines) < linesBatchSize {
+				var l []byte
+				l, err = rd.ReadBytes('\n')
+				if len(l) > 0 {
+					b.lines = append(b.lines, l)
+				}
+				if err != nil {
+					break
+				}
+			}
+			line += len(b.lines)
+			if len(b.lines) > 0 {
+				select {
+				case order <- b:
+				case <-stop:
+					return
+				}
+				jobs <- b
+			}
+			if err != nil {
+				if err != io.EOF {
+					rerr = err
+				}
+				return
+			}
+		}
+	}()
+	var err error
+	for b := range order {
+		<-b.done
+		if err == nil {
+			if _, err = w.Write(b.out); err == nil {
+				err = b.err
+			}
+			if err != nil {
+				close(stop)
+			}
+		}
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	return rerr
+}
+
+// SetLines sets a value for the specified path on every line of a
+// newline-delimited json stream.
+func SetLines(w io.Writer, r io.Reader, path string, value interface{}) error {
+	return EditLines(w, r, []Op{{Kind: OpSet, Path: path, Value: value}}, nil)
+}
+
+// SetRawLines sets a raw json value for the specified path on every line of
+// a newline-delimited json stream.
+func SetRawLines(w io.Writer, r io.Reader, path, value string) error {
+	return EditLines(w, r, []Op{{Kind: OpSetRaw, Path: path, Value: value}},
+		nil)
+}
+
+// DeleteLines deletes the value for the specified path on every line of a
+// newline-delimited json stream.
+func DeleteLines(w io.Writer, r io.Reader, path string) error {
+	return EditLines(w, r, []Op{{Kind: OpDelete, Path: path}}, nil)
+}
@@ -0,0 +1,98 @@
+package sjson
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEditLines(t *testing.T) {
+	input := "{\"level\":\"info\",\"user\":{\"name\":\"Tom\",\"password\":\"x\"}}\n" +
+		"\n" +
+		"{\"level\":\"error\",\"user\":{\"name\":\"Sara\",\"password\":\"y\"}}\r\n" +
+		"{\"level\":\"error\",\"user\":{\"name\":\"Jack\"}}"
+	ops := []Op{
+		{Kind: OpDelete, Path: "user.password"},
+		{Kind: OpSet, Path: "seen", Value: true},
+	}
+	var w bytes.Buffer
+	err := EditLines(&w, strings.NewReader(input), ops,
+		&LinesOptions{Filter: `level=="error"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "{\"level\":\"info\",\"user\":{\"name\":\"Tom\",\"password\":\"x\"}}\n" +
+		"\n" +
+		"{\"level\":\"error\",\"user\":{\"name\":\"Sara\"},\"seen\":true}\r\n" +
+		"{\"level\":\"error\",\"user\":{\"name\":\"Jack\"},\"seen\":true}"
+	if w.String() != expect {
+		t.Fatalf("expected '%v', got '%v'", expect, w.String())
+	}
+	w.Reset()
+	if err := DeleteLines(&w, strings.NewReader(input), "level"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(w.String(), "level") {
+		t.Fatalf("unexpected result '%v'", w.String())
+	}
+}
+
+func TestEditLinesOrder(t *testing.T) {
+	var input, expect strings.Builder
+	for i := 0; i < 5000; i++ {
+		g := strconv.Itoa(i % 7)
+		input.WriteString(`{"id":` + strconv.Itoa(i) + `,"g":` + g + "}\n")
+		expect.WriteString(`{"id":` + strconv.Itoa(i) + `,"g":` + g +
+			`,"n":` + g + "}\n")
+	}
+	var w bytes.Buffer
+	for i := 0; i < 7; i++ {
+		err := EditLines(&w, strings.NewReader(input.String()), []Op{
+			{Kind: OpSetRaw, Path: "n", Value: strconv.Itoa(i)},
+		}, &LinesOptions{Filter: "g==" + strconv.Itoa(i), Workers: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		input.Reset()
+		input.Write(w.Bytes())
+		w.Reset()
+	}
+	if input.String() != expect.String() {
+		t.Fatal("unexpected result")
+	}
+}
+
+func TestEditLinesError(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		input.WriteString(`{"a":[1]}` + "\n")
+	}
+	input.WriteString(`{"a":{}}` + "\n")
+	var w bytes.Buffer
+	err := SetLines(&w, strings.NewReader(input.String()), "a.b", 1)
+	var lerr *LineError
+	if !errors.As(err, &lerr) || lerr.Line != 1 ||
+		!errors.Is(err, ErrNonNumericKey) {
+		t.Fatalf("expected LineError on line 1, got %v", err)
+	}
+	if w.Len() != 0 {
+		t.Fatalf("unexpected output '%v'", w.String())
+	}
+	// the lines before the error are written, even in the same batch
+	input.Reset()
+	for i := 0; i < 300; i++ {
+		input.WriteString(`{"a":{}}` + "\n")
+	}
+	input.WriteString(`{"a":[1]}` + "\n" + `{"a":{}}` + "\n")
+	w.Reset()
+	err = SetLines(&w, strings.NewReader(input.String()), "a.b", 1)
+	if !errors.As(err, &lerr) || lerr.Line != 301 {
+		t.Fatalf("expected LineError on line 301, got %v", err)
+	}
+	expect := strings.Repeat(`{"a":{"b":1}}`+"\n", 300)
+	if w.String() != expect {
+		t.Fatalf("unexpected output '%v'", w.String())
+	}
+}
@@ -0,0 +1,59 @@
+package sjson
+
+import (
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// mergePatch applies the patch to the target json following the algorithm
+// in RFC 7386, section 2.
+func mergePatch(target string, patch gjson.Result) string {
+	if !patch.IsObject() {
+		return patch.Raw
+	}
+	if !gjson.Parse(target).IsObject() {
+		target = "{}"
+	}
+	patch.ForEach(func(key, value gjson.Result) bool {
+		paths := []pathResult{{part: key.Str, gpart: escapeComp(key.Str)}}
+		if value.Type == gjson.Null {
+			target, _ = setPaths(target, paths, setOp{del: true})
+			return true
+		}
+		raw := value.Raw
+		if value.IsObject() {
+			cur, _ := getPaths(target, paths)
+			raw = mergePatch(cur, value)
+		}
+		target, _ = setPaths(target, paths, setOp{raw: raw})
+		return true
+	})
+	return target
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) document to the json.
+// Objects in the patch are merged recursively, a null value deletes the
+// member, and all other values replace the existing value.
+// Members that are not touched by the patch keep their original order and
+// formatting.
+// An error is returned if the patch is not valid json.
+func MergePatch(json, patch string) (string, error) {
+	if !gjson.Valid(patch) {
+		return json, ErrInvalidJSON
+	}
+	return mergePatch(json, gjson.Parse(patch)), nil
+}
+
+// MergePatchBytes applies a JSON Merge Patch (RFC 7386) document to the json.
+// If working with bytes, this method preferred over
+// MergePatch(string(data), string(patch))
+func MergePatchBytes(json, patch []byte) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	pstr := *(*string)(unsafe.Pointer(&patch))
+	res, err := MergePatch(jstr, pstr)
+	if err != nil {
+		return json, err
+	}
+	return []byte(res), nil
+}
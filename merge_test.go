@@ -0,0 +1,49 @@
+package sjson
+
+import "testing"
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		json, patch, expect string
+	}{
+		// examples from RFC 7386, Appendix A
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// formatting and key order are preserved
+		{`{ "z": 1, "a": { "x": 1.50, "y": 2 } }`, `{"a":{"y":3}}`,
+			`{ "z": 1, "a": { "x": 1.50, "y": 3 } }`},
+		{`{"a.b":1,"c":2}`, `{"a.b":null,"*":3}`, `{"c":2,"*":3}`},
+	}
+	for i, tt := range tests {
+		res, err := MergePatch(tt.json, tt.patch)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+		resb, err := MergePatchBytes([]byte(tt.json), []byte(tt.patch))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if string(resb) != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, resb)
+		}
+	}
+	if _, err := MergePatch(`{}`, `{"a":`); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,433 @@
+package sjson
+
+import (
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// parsePointer converts a JSON Pointer (RFC 6901) into path components. The
+// "-" token is kept as a key, as it only refers to the end of an array when
+// its parent is an array, which is found out by resolvePointer.
+func parsePointer(ptr string) ([]pathResult, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if ptr[0] != '/' {
+		return nil, ErrInvalidPointer
+	}
+	var paths []pathResult
+	for len(ptr) > 0 {
+		ptr = ptr[1:]
+		var tok string
+		i := 0
+		for ; i < len(ptr); i++ {
+			if ptr[i] == '/' {
+				break
+			}
+		}
+		tok, ptr = ptr[:i], ptr[i:]
+		for i := 0; i < len(tok); i++ {
+			if tok[i] == '~' {
+				if i+1 == len(tok) || (tok[i+1] != '0' && tok[i+1] != '1') {
+					return nil, ErrInvalidPointer
+				}
+				tok = unescapePointerToken(tok)
+				break
+			}
+		}
+		var r pathResult
+		switch tok {
+		case "-1":
+			r.part = tok
+			r.gpart = tok
+			r.force = true
+		default:
+			r.part = tok
+			r.gpart = escapeComp(tok)
+		}
+		paths = append(paths, r)
+	}
+	for i := 0; i < len(paths)-1; i++ {
+		paths[i].more = true
+	}
+	return paths, nil
+}
+
+// resolvePointer returns the components with the "-" token that refers to
+// the end of an array in jstr replaced by "-1". A "-" token for an object,
+// or for a value that does not exist, is the "-" member.
+func resolvePointer(jstr string, paths []pathResult) []pathResult {
+	raw := jstr
+	for i, p := range paths {
+		if p.part == "-" && isArray(raw) {
+			paths = append([]pathResult(nil), paths...)
+			paths[i].part, paths[i].gpart = "-1", "-1"
+			return paths
+		}
+		if raw = gjson.Get(raw, p.gpart).Raw; raw == "" {
+			break
+		}
+	}
+	return paths
+}
+
+func unescapePointerToken(tok string) string {
+	b := make([]byte, 0, len(tok))
+	for i := 0; i < len(tok); i++ {
+		if tok[i] == '~' && i+1 < len(tok) {
+			if tok[i+1] == '0' {
+				b = append(b, '~')
+			} else {
+				b = append(b, '/')
+			}
+			i++
+			continue
+		}
+		b = append(b, tok[i])
+	}
+	return string(b)
+}
+
+// getPaths returns the raw value for the paths in jstr.
+func getPaths(jstr string, paths []pathResult) (raw string, ok bool) {
+	if len(paths) == 0 {
+		return jstr, true
+	}
+	index, n, ok := locatePaths(jstr, paths, false)
+	if !ok {
+		return "", false
+	}
+	return jstr[index : index+n], true
+}
+
+// setPaths performs the operation on the value for the paths in jstr.
+func setPaths(jstr string, paths []pathResult, op setOp) (string, error) {
+	res, err := appendRawPaths(nil, jstr, paths, &op)
+	if err != nil {
+		return jstr, err
+	}
+	return string(res), nil
+}
+
+// arrayIndex returns the array index for a path component. The ok return
+// value is false if the component is not a valid index.
+func arrayIndex(r pathResult) (n int, ok bool) {
+	if len(r.part) == 0 || (len(r.part) > 1 && r.part[0] == '0') {
+		return 0, false
+	}
+	return atoui(r)
+}
+
+// patchAdd performs the "add" operation, which inserts into arrays and
+// creates or replaces object members.
+func patchAdd(jstr string, paths []pathResult, raw string) (string, error) {
+	if len(paths) == 0 {
+		return raw, nil
+	}
+	paths = resolvePointer(jstr, paths)
+	last := paths[len(paths)-1]
+	parent, ok := getPaths(jstr, paths[:len(paths)-1])
+	if !ok {
+		return jstr, ErrNotFound
+	}
+	pres := gjson.Parse(parent)
+	if !pres.IsObject() && !pres.IsArray() {
+		return jstr, ErrNotFound
+	}
+	if pres.IsObject() || (last.part == "-1" && !last.force) {
+		return setPaths(jstr, paths, setOp{raw: raw})
+	}
+	n, ok := arrayIndex(last)
+	if !ok {
+		return jstr, ErrInvalidPointer
+	}
+	count := int(pres.Get("#").Int())
+	if n > count {
+		return jstr, ErrNotFound
+	}
+	if n == count {
+		// append to the end of the array
+		last.part, last.gpart = "-1", "-1"
+		paths = append(paths[:len(paths)-1:len(paths)-1], last)
+	}
+	return setPaths(jstr, paths, setOp{raw: raw, insert: true})
+}
+
+// patchRemove performs the "remove" operation.
+func patchRemove(jstr string, paths []pathResult) (string, error) {
+	if len(paths) == 0 {
+		return jstr, ErrInvalidPointer
+	}
+	if _, ok := getPaths(jstr, paths); !ok {
+		return jstr, ErrNotFound
+	}
+	return setPaths(jstr, paths, setOp{del: true})
+}
+
+// isPrefixPath returns true if prefix is the same as, or a parent of, paths.
+func isPrefixPath(prefix, paths []pathResult) bool {
+	if len(prefix) > len(paths) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i].part != paths[i].part {
+			return false
+		}
+	}
+	return true
+}
+
+func applyPatchOp(jstr string, op gjson.Result) (string, error) {
+	var err error
+	var paths, from []pathResult
+	name := op.Get("op").String()
+	pathRes := op.Get("path")
+	if pathRes.Type != gjson.String {
+		return jstr, ErrInvalidOperation
+	}
+	if paths, err = parsePointer(pathRes.String()); err != nil {
+		return jstr, err
+	}
+	value := op.Get("value")
+	switch name {
+	case "add", "replace", "test":
+		if !value.Exists() {
+			return jstr, ErrInvalidOperation
+		}
+	case "move", "copy":
+		fromRes := op.Get("from")
+		if fromRes.Type != gjson.String {
+			return jstr, ErrInvalidOperation
+		}
+		if from, err = parsePointer(fromRes.String()); err != nil {
+			return jstr, err
+		}
+	}
+	switch name {
+	case "add":
+		return patchAdd(jstr, paths, value.Raw)
+	case "remove":
+		return patchRemove(jstr, paths)
+	case "replace":
+		if _, ok := getPaths(jstr, paths); !ok {
+			return jstr, ErrNotFound
+		}
+		if len(paths) == 0 {
+			return value.Raw, nil
+		}
+		return setPaths(jstr, paths, setOp{raw: value.Raw})
+	case "move":
+		raw, ok := getPaths(jstr, from)
+		if !ok {
+			return jstr, ErrNotFound
+		}
+		if isPrefixPath(from, paths) {
+			if len(from) == len(paths) {
+				return jstr, nil
+			}
+			return jstr, ErrMoveIntoSelf
+		}
+		if jstr, err = patchRemove(jstr, from); err != nil {
+			return jstr, err
+		}
+		return patchAdd(jstr, paths, raw)
+	case "copy":
+		raw, ok := getPaths(jstr, from)
+		if !ok {
+			return jstr, ErrNotFound
+		}
+		return patchAdd(jstr, paths, raw)
+	case "test":
+		raw, ok := getPaths(jstr, paths)
+		if !ok || !jsonEqual(gjson.Parse(raw), value) {
+			return jstr, ErrTestFailed
+		}
+		return jstr, nil
+	default:
+		return jstr, ErrInvalidOperation
+	}
+}
+
+// jsonEqual returns true if a and b are equal json values. Object members
+// may appear in any order.
+func jsonEqual(a, b gjson.Result) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case gjson.String:
+		return a.Str == b.Str
+	case gjson.Number:
+		if a.Raw == b.Raw {
+			return true
+		}
+		return numberEqual(trim(a.Raw), trim(b.Raw), a.Num == b.Num)
+	case gjson.JSON:
+		if a.IsArray() != b.IsArray() {
+			return false
+		}
+		if a.IsArray() {
+			aa, ba := a.Array(), b.Array()
+			if len(aa) != len(ba) {
+				return false
+			}
+			for i := range aa {
+				if !jsonEqual(aa[i], ba[i]) {
+					return false
+				}
+			}
+			return true
+		}
+		am, bm := a.Map(), b.Map()
+		if len(am) != len(bm) {
+			return false
+		}
+		for k, av := range am {
+			bv, ok := bm[k]
+			if !ok || !jsonEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// numberEqual returns true if the json numbers a and b have the same value.
+// They are compared as decimals, so that large integers and long fractions
+// are compared exactly. The result is float when an exponent is too large.
+func numberEqual(a, b string, float bool) bool {
+	aneg, adigits, aexp, ok := normalizeNumber(a)
+	if !ok {
+		return float
+	}
+	bneg, bdigits, bexp, ok := normalizeNumber(b)
+	if !ok {
+		return float
+	}
+	return aneg == bneg && adigits == bdigits && aexp == bexp
+}
+
+// normalizeNumber returns the sign, the digits without leading and trailing
+// zeros, and the exponent of a json number, whose value is the digits times
+// ten to the power of the exponent. Zero has no digits.
+func normalizeNumber(raw string) (neg bool, digits string, exp int,
+	ok bool) {
+	if len(raw) > 0 && raw[0] == '-' {
+		neg, raw = true, raw[1:]
+	}
+	if i := strings.IndexAny(raw, "eE"); i != -1 {
+		n, err := strconv.Atoi(raw[i+1:])
+		if err != nil {
+			return false, "", 0, false
+		}
+		exp, raw = n, raw[:i]
+	}
+	if dot := strings.IndexByte(raw, '.'); dot != -1 {
+		exp -= len(raw) - dot - 1
+		raw = raw[:dot] + raw[dot+1:]
+	}
+	raw = strings.TrimLeft(raw, "0")
+	digits = strings.TrimRight(raw, "0")
+	if digits == "" {
+		return false, "", 0, true
+	}
+	return neg, digits, exp + len(raw) - len(digits), true
+}
+
+// ApplyPatch applies a JSON Patch (RFC 6902) document to the json.
+// The patch is applied atomically: when any operation fails the original
+// json is returned along with a *PatchError describing the failing
+// operation.
+//
+//	[
+//	  {"op": "replace", "path": "/name/last", "value": "Smith"},
+//	  {"op": "add", "path": "/children/0", "value": "Bob"},
+//	  {"op": "remove", "path": "/age"}
+//	]
+//
+// Paths are JSON Pointers (RFC 6901), such as "/friends/0/last".
+func ApplyPatch(json, patch string) (string, error) {
+	jstr := json
+	if !gjson.Valid(patch) {
+		return json, ErrInvalidPatch
+	}
+	ops := gjson.Parse(patch)
+	if !ops.IsArray() {
+		return json, ErrInvalidPatch
+	}
+	var err error
+	var i int
+	ops.ForEach(func(_, op gjson.Result) bool {
+		if jstr, err = applyPatchOp(jstr, op); err != nil {
+			err = &PatchError{
+				Index: i,
+				Op:    op.Get("op").String(),
+				Path:  op.Get("path").String(),
+				Err:   err,
+			}
+			return false
+		}
+		i++
+		return true
+	})
+	if err != nil {
+		return json, err
+	}
+	return jstr, nil
+}
+
+// ApplyPatchBytes applies a JSON Patch (RFC 6902) document to the json.
+// If working with bytes, this method preferred over
+// ApplyPatch(string(data), string(patch))
+func ApplyPatchBytes(json, patch []byte) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	pstr := *(*string)(unsafe.Pointer(&patch))
+	res, err := ApplyPatch(jstr, pstr)
+	if err != nil {
+		return json, err
+	}
+	return []byte(res), nil
+}
+
+// setPointer performs the operation on the value for the JSON Pointer.
+func setPointer(jstr, ptr string, op *setOp) ([]byte, error) {
+	paths, err := parsePointer(ptr)
+	if err != nil {
+		return []byte(jstr), &PathError{Path: ptr, Component: -1, Offset: -1,
+			Err: err}
+	}
+	return setPointerPaths(jstr, ptr, paths, op)
+}
+
+// setPointerPaths performs the operation on the value for the components
+// of a JSON Pointer.
+func setPointerPaths(jstr, ptr string, paths []pathResult,
+	op *setOp) ([]byte, error) {
+	if len(paths) == 0 {
+		// the pointer refers to the whole document
+		if op.del {
+			return []byte(jstr), &PathError{Path: ptr, Component: -1,
+				Offset: -1, Err: ErrEmptyPath}
+		}
+		var err error
+		raw := trim(jstr)
+		if raw == "" {
+			err = op.checkMissing()
+		} else if err = op.checkFound(raw); err == nil {
+			err = op.apply(raw)
+		}
+		if err != nil {
+			return []byte(jstr), &PathError{Path: ptr, Component: -1,
+				Offset: 0, Err: err}
+		}
+		njson := op.appendValue(op.dst)
+		op.record(0, len(jstr), len(njson)-len(op.dst),
+			string(njson[len(op.dst):]) != jstr)
+		return njson, nil
+	}
+	return setSimplePath(jstr, ptr, resolvePointer(jstr, paths), op)
+}
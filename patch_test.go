@@ -0,0 +1,171 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		json, patch, expect string
+	}{
+		// examples from RFC 6902, Appendix A
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"foo":"bar","baz":"qux"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			`{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`,
+			`{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`,
+			`{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"replace","path":"/baz","value":"boo"}]`,
+			`{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{`{"foo":"bar"}`,
+			`[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			`{"foo":["bar",["abc","def"]]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`,
+			`{"/":9,"~1":10}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[
+			{"op":"test","path":"/baz","value":"qux"},
+			{"op":"test","path":"/foo/1","value":2}
+		]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		// other cases
+		{`{"a.b":{"c":1}}`, `[{"op":"copy","from":"/a.b/c","path":"/a.b/d"}]`,
+			`{"a.b":{"c":1,"d":1}}`},
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/2","value":3}]`,
+			`{"a":[1,2,3]}`},
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/0","value":0}]`,
+			`{"a":[0,1,2]}`},
+		{`{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		{`{"a":{"b":1}}`, `[{"op":"test","path":"/a","value":{"b":1.0}}]`,
+			`{"a":{"b":1}}`},
+		{`{"a":1}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":1}`},
+		{`{"a":1}`, `[{"op":"add","path":"/-","value":2}]`, `{"a":1,"-":2}`},
+		{`{"-":1}`, `[{"op":"replace","path":"/-","value":2}]`, `{"-":2}`},
+		{`{"-":1,"a":2}`, `[{"op":"remove","path":"/-"}]`, `{"a":2}`},
+		{`{"-":[1]}`, `[{"op":"add","path":"/-/-","value":2}]`, `{"-":[1,2]}`},
+		{`{"a":9007199254740993}`,
+			`[{"op":"test","path":"/a","value":9007199254740993}]`,
+			`{"a":9007199254740993}`},
+		{`{"a":[100,0.10,-0]}`,
+			`[{"op":"test","path":"/a","value":[1e2,1E-1,0.0]}]`,
+			`{"a":[100,0.10,-0]}`},
+		{`{"a":{"":1}}`, `[{"op":"add","path":"/","value":2}]`,
+			`{"a":{"":1},"":2}`},
+		{`{"":{"":1}}`, `[
+			{"op":"test","path":"//","value":1},
+			{"op":"copy","from":"//","path":"/b"},
+			{"op":"remove","path":"/"}
+		]`, `{"b":1}`},
+	}
+	for i, tt := range tests {
+		res, err := ApplyPatch(tt.json, tt.patch)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+		resb, err := ApplyPatchBytes([]byte(tt.json), []byte(tt.patch))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if string(resb) != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, resb)
+		}
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		json, patch string
+		index       int
+	}{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, 0},
+		{`{"a":9007199254740993}`,
+			`[{"op":"test","path":"/a","value":9007199254740992}]`, 0},
+		{`{"a":{"":1}}`, `[{"op":"remove","path":"/"}]`, 0},
+		{`{"foo":"bar"}`, `[
+			{"op":"add","path":"/a","value":1},
+			{"op":"add","path":"/baz/bat","value":"qux"}
+		]`, 1},
+		{`{"a":[1]}`, `[{"op":"add","path":"/a/5","value":1}]`, 0},
+		{`{"a":[1]}`, `[{"op":"remove","path":"/b"}]`, 0},
+		{`{"a":[1]}`, `[{"op":"replace","path":"/a/-","value":1}]`, 0},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, 0},
+		{`{"a":1}`, `[{"op":"add","path":"/a"}]`, 0},
+		{`{"a":1}`, `[{"op":"nope","path":"/a"}]`, 0},
+		{`{"a":1}`, `[{"op":"add","path":"a","value":1}]`, 0},
+	}
+	for i, tt := range tests {
+		res, err := ApplyPatch(tt.json, tt.patch)
+		var perr *PatchError
+		if !errors.As(err, &perr) {
+			t.Fatalf("test %d: expected a *PatchError, got %v", i, err)
+		}
+		if perr.Index != tt.index {
+			t.Fatalf("test %d: expected index %d, got %d", i, tt.index,
+				perr.Index)
+		}
+		if res != tt.json {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.json, res)
+		}
+	}
+	if _, err := ApplyPatch(`{}`, `{"op":"add"}`); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestPointerOption(t *testing.T) {
+	opts := &Options{Pointer: true}
+	tests := []struct {
+		json, path string
+		value      interface{}
+		expect     string
+	}{
+		{`{"a.b":{"c":1}}`, "/a.b/c", 2, `{"a.b":{"c":2}}`},
+		{`{"a/b":1,"m~n":2}`, "/a~1b", 3, `{"a/b":3,"m~n":2}`},
+		{`{"a/b":1,"m~n":2}`, "/m~0n", 3, `{"a/b":1,"m~n":3}`},
+		{`{"a":[1,2]}`, "/a/-", 3, `{"a":[1,2,3]}`},
+		{`{"a":[1,2]}`, "/a/0", 3, `{"a":[3,2]}`},
+		{`{}`, "/a/b*c/d", "x", `{"a":{"b*c":{"d":"x"}}}`},
+		{`{"":1}`, "/", 2, `{"":2}`},
+		{`{"a":1}`, "", []int{1}, `[1]`},
+		{`{"a":1,"b":2}`, "/a", dtype{}, `{"b":2}`},
+		{`{"a":[1,2]}`, "/a/-", dtype{}, `{"a":[1]}`},
+		{`{"#":1}`, "/#", 2, `{"#":2}`},
+		{`{"a":{"-":1}}`, "/a/-", 9, `{"a":{"-":9}}`},
+		{`{"a":{}}`, "/a/-", 9, `{"a":{"-":9}}`},
+	}
+	for i, tt := range tests {
+		json, err := SetOptions(tt.json, tt.path, tt.value, opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+	}
+	_, err := SetOptions(`{}`, "a", 1, opts)
+	if !errors.Is(err, ErrInvalidPointer) {
+		t.Fatalf("expected ErrInvalidPointer, got %v", err)
+	}
+	_, err = SetOptions(`{"a":[]}`, "/a/b", 1, opts)
+	if !errors.Is(err, ErrNonNumericKey) {
+		t.Fatalf("expected ErrNonNumericKey, got %v", err)
+	}
+	n, err := IncrementOptions(`{"a":{"b.c":1}}`, "/a/b.c", 1, opts)
+	if err != nil || n != `{"a":{"b.c":2}}` {
+		t.Fatalf("unexpected result '%v', %v", n, err)
+	}
+}
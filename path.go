@@ -0,0 +1,159 @@
+package sjson
+
+import "unsafe"
+
+// Path is a compiled path. Compiling a path that is used many times avoids
+// parsing it on every call. A Path is safe for concurrent use.
+type Path struct {
+	path    string
+	paths   []pathResult // components, or nil for a complex path
+	pointer bool         // the path is a JSON Pointer
+}
+
+// CompilePath parses a path for use with SetPath and DeletePath.
+// Simple paths are split into their components, with escapes and
+// colon-forced keys resolved. Paths with queries, wildcards or modifiers are
+// kept as is and are evaluated on each call.
+func CompilePath(path string) (*Path, error) {
+	if path == "" {
+		return nil, &PathError{Component: -1, Offset: -1, Err: ErrEmptyPath}
+	}
+	paths, _ := parseSimplePath(path)
+	return &Path{path: path, paths: paths}, nil
+}
+
+// CompilePointer parses a JSON Pointer (RFC 6901) for use with SetPath and
+// DeletePath.
+func CompilePointer(ptr string) (*Path, error) {
+	paths, err := parsePointer(ptr)
+	if err != nil {
+		return nil, &PathError{Path: ptr, Component: -1, Offset: -1,
+			Err: err}
+	}
+	return &Path{path: ptr, paths: paths, pointer: true}, nil
+}
+
+// String returns the source text of the path.
+func (p *Path) String() string {
+	return p.path
+}
+
+// setCompiled performs the operation on the value for a compiled path. The
+// Pointer option is ignored, as the path has already been parsed.
+func setCompiled(jstr string, p *Path, op *setOp,
+	opts *Options) ([]byte, error) {
+	if p.paths == nil && !p.pointer {
+		if opts != nil && opts.Pointer {
+			nopts := *opts
+			opts = &nopts
+			opts.Pointer = false
+		}
+		return set(jstr, p.path, op, opts)
+	}
+	if opts != nil && (opts.JSONC || opts.JSON5) {
+		nopts := *opts
+		nopts.Pointer = p.pointer
+		return set(jstr, p.path, op, &nopts)
+	}
+	if err := op.prepare(jstr, opts); err != nil {
+		return []byte(jstr), err
+	}
+	if p.pointer {
+		res, err := setPointerPaths(jstr, p.path, p.paths, op)
+		return op.copyInPlace(jstr, res, err)
+	}
+	if op.capacity > 0 && !op.appending {
+		if res, ok, err := setFoundInPlace(jstr, p.path, p.paths, op); ok {
+			return res, err
+		}
+	} else if opts != nil && opts.Optimistic && !op.del && !op.insert {
+		if index, n, ok := locatePaths(jstr, p.paths, false); ok {
+			return replaceValue(jstr, p.path, index, jstr[index:index+n], op,
+				false)
+		}
+	}
+	res, err := setSimplePath(jstr, p.path, p.paths, op)
+	return op.copyInPlace(jstr, res, err)
+}
+
+// SetPath sets a json value for a compiled path.
+//
+//	p, _ := CompilePath("name.last")
+//	SetPath(`{"name":{"last":"Anderson"}}`, p, "Smith")
+//	>> {"name":{"last":"Smith"}}
+func SetPath(json string, path *Path, value interface{}) (string, error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return "", err
+	}
+	res, err := setCompiled(json, path, &op, nil)
+	if err == errNoChange {
+		return json, nil
+	}
+	return string(res), err
+}
+
+// SetBytesPath sets a json value for a compiled path.
+// If working with bytes, this method preferred over
+// SetPath(string(data), path, value)
+func SetBytesPath(json []byte, path *Path, value interface{}) ([]byte,
+	error) {
+	return SetBytesPathOptions(json, path, value, nil)
+}
+
+// SetBytesPathOptions sets a json value for a compiled path with options.
+func SetBytesPathOptions(json []byte, path *Path, value interface{},
+	opts *Options) ([]byte, error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return nil, err
+	}
+	jstr := *(*string)(unsafe.Pointer(&json))
+	op.inPlace(json, opts)
+	res, err := setCompiled(jstr, path, &op, opts)
+	if err == errNoChange {
+		return json, nil
+	}
+	return res, err
+}
+
+// SetRawPath sets a raw json value for a compiled path.
+func SetRawPath(json string, path *Path, value string) (string, error) {
+	res, err := setCompiled(json, path, &setOp{raw: value}, nil)
+	if err == errNoChange {
+		return json, nil
+	}
+	return string(res), err
+}
+
+// SetRawBytesPath sets a raw json value for a compiled path.
+// If working with bytes, this method preferred over
+// SetRawPath(string(data), path, value)
+func SetRawBytesPath(json []byte, path *Path, value []byte) ([]byte, error) {
+	return SetRawBytesPathOptions(json, path, value, nil)
+}
+
+// SetRawBytesPathOptions sets a raw json value for a compiled path with
+// options.
+func SetRawBytesPathOptions(json []byte, path *Path, value []byte,
+	opts *Options) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	vstr := *(*string)(unsafe.Pointer(&value))
+	op := setOp{raw: vstr}
+	op.inPlace(json, opts)
+	res, err := setCompiled(jstr, path, &op, opts)
+	if err == errNoChange {
+		return json, nil
+	}
+	return res, err
+}
+
+// DeletePath deletes a value from json for a compiled path.
+func DeletePath(json string, path *Path) (string, error) {
+	return SetPath(json, path, dtype{})
+}
+
+// DeleteBytesPath deletes a value from json for a compiled path.
+func DeleteBytesPath(json []byte, path *Path) ([]byte, error) {
+	return SetBytesPath(json, path, dtype{})
+}
@@ -0,0 +1,77 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompilePath(t *testing.T) {
+	tests := []struct {
+		json, path string
+		pointer    bool
+		value      interface{}
+		expect     string
+	}{
+		{`{"a":{"b":1}}`, "a.b", false, 2, `{"a":{"b":2}}`},
+		{`{"a.b":1}`, `a\.b`, false, 2, `{"a.b":2}`},
+		{`{}`, "a.:1", false, 2, `{"a":{"1":2}}`},
+		{`{"a":[1,2]}`, "a.-1", false, 3, `{"a":[1,2,3]}`},
+		{`{"a":[1,2]}`, "a.#(==2)", false, 3, `{"a":[1,3]}`},
+		{`{"a":[1,2]}`, "/a/0", true, 3, `{"a":[3,2]}`},
+		{`{"a":1}`, "", true, 3, `3`},
+		{`{"a":1,"b":2}`, "a", false, dtype{}, `{"b":2}`},
+		{`{"a":[1,2]}`, "/a/-", true, dtype{}, `{"a":[1]}`},
+	}
+	for i, tt := range tests {
+		var p *Path
+		var err error
+		if tt.pointer {
+			p, err = CompilePointer(tt.path)
+		} else {
+			p, err = CompilePath(tt.path)
+		}
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if p.String() != tt.path {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.path, p)
+		}
+		for j := 0; j < 2; j++ {
+			json, err := SetPath(tt.json, p, tt.value)
+			if err != nil {
+				t.Fatalf("test %d: %v", i, err)
+			}
+			if json != tt.expect {
+				t.Fatalf("test %d: expected '%v', got '%v'",
+					i, tt.expect, json)
+			}
+		}
+	}
+	if _, err := CompilePath(""); !errors.Is(err, ErrEmptyPath) {
+		t.Fatalf("expected ErrEmptyPath, got %v", err)
+	}
+	if _, err := CompilePointer("a"); !errors.Is(err, ErrInvalidPointer) {
+		t.Fatalf("expected ErrInvalidPointer, got %v", err)
+	}
+}
+
+func TestSetPathOptions(t *testing.T) {
+	p, _ := CompilePath("a.b")
+	opts := &Options{Optimistic: true, ReplaceInPlace: true}
+	json := []byte(`{"a":{"b":100}}`)
+	res, err := SetRawBytesPathOptions(json, p, []byte("1"), opts)
+	if err != nil || string(res) != `{"a":{"b":1}}` {
+		t.Fatalf("unexpected result '%s', %v", res, err)
+	}
+	if &res[0] != &json[0] {
+		t.Fatal("expected the json to be replaced in place")
+	}
+	_, err = SetBytesPathOptions([]byte(`{"a":[]}`), p, 1, nil)
+	if !errors.Is(err, ErrNonNumericKey) {
+		t.Fatalf("expected ErrNonNumericKey, got %v", err)
+	}
+	var perr *PathError
+	if !errors.As(err, &perr) || perr.Path != "a.b" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package sjson
+
+import (
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// Rename changes the key of the object member at the specified path to
+// newKey. Only the key is rewritten, so the member keeps its position and
+// its value is not copied. The path must be a simple path.
+//
+//	Rename(`{"name":"Tom","age":37}`, "name", "first")
+//	>> {"first":"Tom","age":37}
+//
+// The json is returned as is when the path does not exist. ErrNotObject is
+// returned when the value is an array element, and ErrKeyExists is returned
+// when the object already has a member named newKey.
+func Rename(json, path, newKey string) (string, error) {
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, err := RenameBytes(jsonb, path, newKey)
+	return string(res), err
+}
+
+// RenameBytes changes the key of the object member at the specified path.
+// If working with bytes, this method preferred over
+// Rename(string(data), path, newKey)
+func RenameBytes(json []byte, path, newKey string) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	paths, err := simplePaths(path)
+	if err != nil {
+		return json, err
+	}
+	index, _, ok := locatePaths(jstr, paths, false)
+	if !ok {
+		return json, nil
+	}
+	var pstart int
+	parent := jstr
+	if len(paths) > 1 {
+		var n int
+		pstart, n, _ = locatePaths(jstr, paths[:len(paths)-1], false)
+		parent = jstr[pstart : pstart+n]
+	}
+	pres := gjson.Parse(parent)
+	if !pres.IsObject() {
+		return json, &PathError{Path: path, Component: len(paths) - 1,
+			Key: paths[len(paths)-1].part, Offset: pstart, Err: ErrNotObject}
+	}
+	pstart += len(parent) - len(pres.Raw)
+	var kstart, kend int
+	var exists bool
+	pres.ForEach(func(key, value gjson.Result) bool {
+		if pstart+value.Index == index {
+			kstart = pstart + key.Index
+			kend = kstart + len(key.Raw)
+		} else if key.Str == newKey {
+			exists = true
+		}
+		return true
+	})
+	if exists {
+		return json, &PathError{Path: path, Component: len(paths) - 1,
+			Key: paths[len(paths)-1].part, Offset: pstart, Err: ErrKeyExists}
+	}
+	buf := make([]byte, 0, len(jstr)-(kend-kstart)+len(newKey)+2)
+	buf = append(buf, jstr[:kstart]...)
+	buf = appendStringify(buf, newKey)
+	return append(buf, jstr[kend:]...), nil
+}
+
+// simplePaths returns the components of a path that must be simple.
+func simplePaths(path string) ([]pathResult, error) {
+	if path == "" {
+		return nil, &PathError{Component: -1, Offset: -1, Err: ErrEmptyPath}
+	}
+	paths, ok := parseSimplePath(path)
+	if !ok {
+		return nil, &PathError{Path: path, Component: -1, Offset: -1,
+			Err: ErrComplexPath}
+	}
+	return paths, nil
+}
+
+// Move moves the value at the from path to the to path. This is the same as
+// deleting the value and then setting it at the to path, so array indexes in
+// the to path refer to the array after the value is removed. The from path
+// must be a simple path.
+//
+//	Move(`{"name":"Tom","old":{"age":37}}`, "old.age", "age")
+//	>> {"name":"Tom","old":{},"age":37}
+//
+// The json is returned as is when the from path does not exist, and
+// ErrMoveIntoSelf is returned when to is a child of from. When an error is
+// returned the json is left unmodified.
+func Move(json, from, to string) (string, error) {
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, err := MoveBytes(jsonb, from, to)
+	return string(res), err
+}
+
+// MoveBytes moves the value at the from path to the to path.
+// If working with bytes, this method preferred over
+// Move(string(data), from, to)
+func MoveBytes(json []byte, from, to string) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	paths, err := simplePaths(from)
+	if err != nil {
+		return json, err
+	}
+	index, n, ok := locatePaths(jstr, paths, true)
+	if !ok {
+		return json, nil
+	}
+	if from == to {
+		return json, nil
+	}
+	if tpaths, ok := parseSimplePath(to); ok && isPrefixPath(paths, tpaths) {
+		return json, &PathError{Path: to, Component: -1, Offset: -1,
+			Err: ErrMoveIntoSelf}
+	}
+	raw := jstr[index : index+n]
+	res, err := appendRawPaths(nil, jstr, paths, &setOp{del: true})
+	if err != nil {
+		return json, err
+	}
+	rstr := *(*string)(unsafe.Pointer(&res))
+	res, err = set(rstr, to, &setOp{raw: raw}, nil)
+	if err == errNoChange {
+		// nothing matched the to path, so keep the value where it is
+		return json, nil
+	}
+	if err != nil {
+		return json, err
+	}
+	return res, nil
+}
+
+// Copy sets the value at the to path to a copy of the value at the from
+// path. The from path may be any gjson path.
+//
+//	Copy(`{"name":"Tom"}`, "name", "nick")
+//	>> {"name":"Tom","nick":"Tom"}
+//
+// The json is returned as is when the from path does not exist.
+func Copy(json, from, to string) (string, error) {
+	res := gjson.Get(json, from)
+	if !res.Exists() {
+		return json, nil
+	}
+	return SetRaw(json, to, res.Raw)
+}
+
+// CopyBytes sets the value at the to path to a copy of the value at the from
+// path. If working with bytes, this method preferred over
+// Copy(string(data), from, to)
+func CopyBytes(json []byte, from, to string) ([]byte, error) {
+	res := gjson.GetBytes(json, from)
+	if !res.Exists() {
+		return json, nil
+	}
+	return SetRawBytes(json, to, []byte(res.Raw))
+}
@@ -0,0 +1,86 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		json, path, key, expect string
+	}{
+		{`{"a":1,"b":2}`, "a", "c", `{"c":1,"b":2}`},
+		{` { "x" : { "a" : 1 , "b" : 2 } } `, "x.b", "c",
+			` { "x" : { "a" : 1 , "c" : 2 } } `},
+		{`{"a\"b":1}`, `a"b`, "a.b", `{"a.b":1}`},
+		{`{"a":1}`, "a", "\n", `{"\n":1}`},
+		{`{"a":1}`, "a", "a", `{"a":1}`},
+		{`{"a":1}`, "b", "c", `{"a":1}`},
+		{`{"a":{"1":{"b":1}}}`, "a.1.b", "c", `{"a":{"1":{"c":1}}}`},
+	}
+	for i, tt := range tests {
+		json, err := Rename(tt.json, tt.path, tt.key)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+	}
+	_, err := Rename(`{"a":1,"b":2}`, "a", "b")
+	if !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+	_, err = Rename(`{"a":[1]}`, "a.0", "b")
+	if !errors.Is(err, ErrNotObject) {
+		t.Fatalf("expected ErrNotObject, got %v", err)
+	}
+	_, err = Rename(`{"a":[1]}`, "a.#(==1)", "b")
+	if !errors.Is(err, ErrComplexPath) {
+		t.Fatalf("expected ErrComplexPath, got %v", err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		json, from, to, expect string
+	}{
+		{`{"a":1,"b":{}}`, "a", "b.c", `{"b":{"c":1}}`},
+		{`{"a":[1,2,3]}`, "a.0", "a.-1", `{"a":[2,3,1]}`},
+		{`{"a":[1,2,3]}`, "a.-1", "b", `{"a":[1,2],"b":3}`},
+		{`{"a":1}`, "a", "a", `{"a":1}`},
+		{`{"a":1}`, "x", "y", `{"a":1}`},
+	}
+	for i, tt := range tests {
+		json, err := Move(tt.json, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+	}
+	json, err := Move(`{"a":{"b":1}}`, "a", "a.b.c")
+	if !errors.Is(err, ErrMoveIntoSelf) || json != `{"a":{"b":1}}` {
+		t.Fatalf("expected ErrMoveIntoSelf, got '%v', %v", json, err)
+	}
+	json, err = Move(`{"a":1,"b":[]}`, "a", "b.x")
+	if err == nil || json != `{"a":1,"b":[]}` {
+		t.Fatalf("expected an error, got '%v', %v", json, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	json, err := Copy(`{"a":{"b":1}}`, "a", "c")
+	if err != nil || json != `{"a":{"b":1},"c":{"b":1}}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	b, err := CopyBytes([]byte(`{"a":[1,2]}`), "a.#(==2)", "b")
+	if err != nil || string(b) != `{"a":[1,2],"b":2}` {
+		t.Fatalf("unexpected result '%s', %v", b, err)
+	}
+	json, err = Copy(`{"a":1}`, "x", "y")
+	if err != nil || json != `{"a":1}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+}
@@ -0,0 +1,108 @@
+package sjson
+
+import "unsafe"
+
+// Result describes the outcome of a set or delete operation.
+type Result struct {
+	// Changed is true if the json was modified. Setting a value to the
+	// same json that it already has is not a change.
+	Changed bool
+	// Matches is the number of values that were set or deleted. This is
+	// greater than one for query paths that match multiple values.
+	Matches int
+	// Start and End are the range of bytes in the new json that were
+	// edited. For deletes the range is empty and marks where the value
+	// was removed. When there are multiple matches the range covers all of
+	// them.
+	Start, End int
+}
+
+// setResult performs the operation and reports its result. When nothing
+// matches the path the json is returned unmodified and the Result is zero.
+func setResult(json []byte, path string, op *setOp,
+	opts *Options) ([]byte, Result, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	op.inPlace(json, opts)
+	res, err := set(jstr, path, op, opts)
+	if err == errNoChange {
+		return json, Result{}, nil
+	}
+	if err != nil {
+		return res, Result{}, err
+	}
+	return res, op.res, nil
+}
+
+// stringOptions returns options that are safe to use with immutable strings.
+func stringOptions(opts *Options) *Options {
+	if opts != nil && opts.ReplaceInPlace {
+		// it's not safe to replace bytes in-place for strings
+		// copy the Options and set options.ReplaceInPlace to false.
+		nopts := *opts
+		opts = &nopts
+		opts.ReplaceInPlace = false
+	}
+	return opts
+}
+
+// SetWithResult sets a json value for the specified path with options, and
+// reports whether the json changed. The opts may be nil.
+//
+//	_, res, _ := SetWithResult(`{"name":"Tom"}`, "name", "Tom", nil)
+//	>> res.Changed == false, res.Matches == 1
+func SetWithResult(json, path string, value interface{},
+	opts *Options) (string, Result, error) {
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, r, err := SetBytesWithResult(jsonb, path, value, stringOptions(opts))
+	return string(res), r, err
+}
+
+// SetBytesWithResult sets a json value for the specified path with options,
+// and reports whether the json changed. If working with bytes, this method
+// preferred over SetWithResult(string(data), path, value, opts)
+func SetBytesWithResult(json []byte, path string, value interface{},
+	opts *Options) ([]byte, Result, error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return nil, Result{}, err
+	}
+	return setResult(json, path, &op, opts)
+}
+
+// SetRawWithResult sets a raw json value for the specified path with
+// options, and reports whether the json changed.
+func SetRawWithResult(json, path, value string,
+	opts *Options) (string, Result, error) {
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, r, err := setResult(jsonb, path, &setOp{raw: value},
+		stringOptions(opts))
+	return string(res), r, err
+}
+
+// SetRawBytesWithResult sets a raw json value for the specified path with
+// options, and reports whether the json changed. If working with bytes, this
+// method preferred over SetRawWithResult(string(data), path, value, opts)
+func SetRawBytesWithResult(json []byte, path string, value []byte,
+	opts *Options) ([]byte, Result, error) {
+	vstr := *(*string)(unsafe.Pointer(&value))
+	return setResult(json, path, &setOp{raw: vstr}, opts)
+}
+
+// DeleteWithResult deletes a value from json for the specified path, and
+// reports whether anything was deleted. A Result with Changed set to false
+// means that the path did not exist.
+func DeleteWithResult(json, path string,
+	opts *Options) (string, Result, error) {
+	return SetWithResult(json, path, dtype{}, opts)
+}
+
+// DeleteBytesWithResult deletes a value from json for the specified path,
+// and reports whether anything was deleted.
+func DeleteBytesWithResult(json []byte, path string,
+	opts *Options) ([]byte, Result, error) {
+	return SetBytesWithResult(json, path, dtype{}, opts)
+}
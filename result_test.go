@@ -0,0 +1,66 @@
+package sjson
+
+import "testing"
+
+func TestSetWithResult(t *testing.T) {
+	tests := []struct {
+		json, path string
+		value      interface{}
+		opts       *Options
+		expect     string
+		res        Result
+	}{
+		{`{"a":1,"b":2}`, "a", 3, nil,
+			`{"a":3,"b":2}`, Result{true, 1, 5, 6}},
+		{`{"a":1,"b":2}`, "a", 1, nil,
+			`{"a":1,"b":2}`, Result{false, 1, 5, 6}},
+		{`{"a":"x"}`, "a", "x", &Options{Optimistic: true},
+			`{"a":"x"}`, Result{false, 1, 5, 8}},
+		{`{"a":1}`, "b", true, nil,
+			`{"a":1,"b":true}`, Result{true, 1, 6, 15}},
+		{`{"a":[1,2]}`, "a.-1", 3, nil,
+			`{"a":[1,2,3]}`, Result{true, 1, 9, 11}},
+		{`{"a":[1,2,1]}`, "a.#(==1)#", 5, nil,
+			`{"a":[5,2,5]}`, Result{true, 2, 6, 11}},
+		{`{"a":[1,2,1]}`, "a.#(==3)#", 5, nil,
+			`{"a":[1,2,1]}`, Result{}},
+		{`{"a":[]}`, "a.#(id==1).b", 5, &Options{Upsert: true},
+			`{"a":[{"id":1,"b":5}]}`, Result{true, 1, 6, 20}},
+		{`{"a":1,"b":2}`, "a", dtype{}, nil,
+			`{"b":2}`, Result{true, 1, 1, 1}},
+		{`{"a":1,"b":2}`, "c", dtype{}, nil,
+			`{"a":1,"b":2}`, Result{}},
+		{`{"a":[1,2,1,3]}`, "a.#(==1)#", dtype{}, nil,
+			`{"a":[2,3]}`, Result{true, 2, 6, 7}},
+	}
+	for i, tt := range tests {
+		json, res, err := SetWithResult(tt.json, tt.path, tt.value, tt.opts)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if json != tt.expect {
+			t.Fatalf("%d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+		if res != tt.res {
+			t.Fatalf("%d: expected %+v, got %+v", i, tt.res, res)
+		}
+	}
+}
+
+func TestDeleteWithResult(t *testing.T) {
+	json := []byte(`{"a":{"b":1}}`)
+	out, res, err := DeleteBytesWithResult(json, "a.c", nil)
+	if err != nil || res.Changed || res.Matches != 0 ||
+		string(out) != string(json) {
+		t.Fatalf("unexpected result %+v, %v", res, err)
+	}
+	out, res, err = DeleteBytesWithResult(json, "a.b", nil)
+	if err != nil || !res.Changed || res.Matches != 1 ||
+		string(out) != `{"a":{}}` {
+		t.Fatalf("unexpected result '%s', %+v, %v", out, res, err)
+	}
+	_, res, err = SetRawWithResult(`{"a":1}`, "", "2", nil)
+	if err == nil || res.Changed {
+		t.Fatalf("expected an error, got %+v", res)
+	}
+}
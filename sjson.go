@@ -2,22 +2,17 @@
 package sjson
 
 import (
+	"encoding"
 	jsongo "encoding/json"
+	"reflect"
 	"sort"
 	"strconv"
+	"time"
 	"unsafe"
 
 	"github.com/tidwall/gjson"
 )
 
-type errorType struct {
-	msg string
-}
-
-func (err *errorType) Error() string {
-	return err.msg
-}
-
 // Options represents additional options for the Set and Delete functions.
 type Options struct {
 	// Optimistic is a hint that the value likely exists which
@@ -29,9 +24,57 @@ type Options struct {
 	// In the case when the destination slice doesn't have enough free
 	// bytes to replace the data in place, a new bytes slice will be
 	// created under the hood.
-	// The Optimistic flag must be set to true and the input must be a
-	// byte slice in order to use this field.
+	// Existing values are replaced or deleted by moving the rest of the
+	// json within the slice, which may grow into its spare capacity. Other
+	// changes, such as adding new values, are built in a temporary buffer
+	// and copied back into the slice.
+	// The input must be a byte slice in order to use this field.
 	ReplaceInPlace bool
+	// Upsert allows for a path with a query that does not match any array
+	// element to create a new element. The query must compare a key with
+	// the "==" operator, such as "friends.#(first==\"Bob\").age", in which
+	// case an object containing the key, value, and the remaining path is
+	// appended to the array. Without a remaining path the value must be an
+	// object, and the key and value of the query are added to it when it is
+	// appended. Other queries return ErrComplexPath when nothing matches.
+	Upsert bool
+	// MatchIndent formats new members, and the containers created for them,
+	// using the indentation, newlines and spacing of the container they are
+	// added to. By default new members are added in compact form.
+	MatchIndent bool
+	// Strict validates the json document and any raw value before the
+	// value is set, returning a *SyntaxError when either is not valid json.
+	// An empty document is allowed, as it is treated as a new document.
+	Strict bool
+	// OnlyIfAbsent only sets the value when the path does not exist.
+	OnlyIfAbsent bool
+	// OnlyIfPresent only sets the value when the path exists. No objects
+	// or arrays are created for missing path components.
+	OnlyIfPresent bool
+	// IfMatch, when not empty, only sets or deletes the value when its
+	// current raw json is equal to IfMatch, ignoring surrounding
+	// whitespace. This allows for a compare-and-swap of the value.
+	//
+	// ErrConditionFailed is returned when the condition of OnlyIfAbsent,
+	// OnlyIfPresent or IfMatch is not met, and the json is left as is.
+	IfMatch string
+	// Pointer interprets the path as a JSON Pointer (RFC 6901), such as
+	// "/friends/0/last", rather than a dot path. The "-" token refers to the
+	// end of an array, or to the "-" member of an object, and the empty
+	// pointer refers to the whole document.
+	Pointer bool
+	// JSONC allows the json to have "//" and "/* */" comments and trailing
+	// commas, as used by VS Code settings and tsconfig.json files. The
+	// comments and trailing commas are kept when the json is edited, and
+	// new members are added after a comment on the line of the last member.
+	JSONC bool
+	// JSON5 allows the json to be a JSON5 document, with unquoted keys,
+	// single-quoted strings, hexadecimal numbers, Infinity, NaN, comments
+	// and trailing commas. Untouched parts keep their original form, and
+	// new keys are unquoted when they are identifiers, unless the document
+	// mostly quotes its keys. Strings are in single quotes when the
+	// document mostly uses them.
+	JSON5 bool
 }
 
 type pathResult struct {
@@ -111,6 +154,31 @@ func parsePath(path string) (res pathResult, simple bool) {
 	return r, true
 }
 
+// escapeComp escapes a single key so that it can be used as a component of
+// a gjson path.
+func escapeComp(comp string) string {
+	for i := 0; i < len(comp); i++ {
+		if !isPathSafeChar(comp[i]) {
+			esc := make([]byte, 0, len(comp)+4)
+			esc = append(esc, comp[:i]...)
+			for ; i < len(comp); i++ {
+				if !isPathSafeChar(comp[i]) {
+					esc = append(esc, '\\')
+				}
+				esc = append(esc, comp[i])
+			}
+			return string(esc)
+		}
+	}
+	return comp
+}
+
+func isPathSafeChar(ch byte) bool {
+	return ch >= 0x80 || ch == '_' || ch == '-' ||
+		(ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'z') ||
+		(ch >= 'A' && ch <= 'Z')
+}
+
 func mustMarshalString(s string) bool {
 	for i := 0; i < len(s); i++ {
 		if s[i] < ' ' || s[i] > 0x7f || s[i] == '"' || s[i] == '\\' {
@@ -132,23 +200,32 @@ func appendStringify(buf []byte, s string) []byte {
 	return buf
 }
 
-// appendBuild builds a json block from a json path.
+// appendBuild builds a json block from a json path. New containers are
+// formatted with st, or compacted when st is nil.
 func appendBuild(buf []byte, array bool, paths []pathResult, raw string,
-	stringify bool) []byte {
+	stringify bool, st *jsonStyle) []byte {
 	if !array {
 		buf = appendStringify(buf, paths[0].part)
-		buf = append(buf, ':')
+		buf = st.appendColon(buf)
 	}
 	if len(paths) > 1 {
+		child := st.child()
 		n, numeric := atoui(paths[1])
 		if numeric || (!paths[1].force && paths[1].part == "-1") {
 			buf = append(buf, '[')
-			buf = appendRepeat(buf, "null,", n)
-			buf = appendBuild(buf, true, paths[1:], raw, stringify)
+			buf = child.appendOpen(buf)
+			for i := 0; i < n; i++ {
+				buf = append(buf, "null"...)
+				buf = child.appendComma(buf)
+			}
+			buf = appendBuild(buf, true, paths[1:], raw, stringify, child)
+			buf = child.appendClose(buf, "")
 			buf = append(buf, ']')
 		} else {
 			buf = append(buf, '{')
-			buf = appendBuild(buf, false, paths[1:], raw, stringify)
+			buf = child.appendOpen(buf)
+			buf = appendBuild(buf, false, paths[1:], raw, stringify, child)
+			buf = child.appendClose(buf, "")
 			buf = append(buf, '}')
 		}
 	} else {
@@ -245,14 +322,158 @@ loop:
 	return buf, false
 }
 
-var errNoChange = &errorType{"no change"}
+// deleteSpan returns the byte range of jstr that must be removed in order to
+// delete the value at index, including its key and one of the adjacent
+// commas.
+func deleteSpan(jstr string, index, n int) (start, end int) {
+	prefix := *(*[]byte)(unsafe.Pointer(&sliceHeader{
+		data: (*stringHeader)(unsafe.Pointer(&jstr)).data,
+		len:  index, cap: index,
+	}))
+	prefix, delNextComma := deleteTailItem(prefix)
+	start, end = len(prefix), index+n
+	if delNextComma {
+		for i := end; i < len(jstr); i++ {
+			if jstr[i] <= ' ' {
+				continue
+			}
+			if jstr[i] == ',' {
+				end = i + 1
+			}
+			break
+		}
+	}
+	return start, end
+}
+
+// setOp holds the value and the mode of a single set or delete operation.
+type setOp struct {
+	raw       string // value to set, or empty when deleting
+	stringify bool   // write raw as a json string
+	del       bool   // delete the value
+	insert    bool   // insert into arrays rather than replace elements
+
+	// update, when not nil, computes the raw value to set from the
+	// existing raw value. The raw field is used when there is no value.
+	update func(raw string) (string, error)
+
+	matchIndent bool      // format new members like the existing ones
+	onlyAbsent  bool      // fail when the value exists
+	onlyPresent bool      // fail when the value does not exist
+	ifMatch     string    // fail when the value is not this raw json
+	style       jsonStyle // style of the container being modified
+
+	dst       []byte // buffer that the new json is appended to
+	appending bool   // appending to dst, so the json must not be modified
+	capacity  int    // capacity of the json bytes when editing in place
+
+	depth  int // index of the current path component
+	offset int // offset of the current container in the json
+
+	res Result // outcome of the operation
+}
 
-func appendRawPaths(buf []byte, jstr string, paths []pathResult, raw string,
-	stringify, del bool) ([]byte, error) {
+// record records an edit that replaced json[start:end] with n bytes. The
+// edited range in the Result is kept up to date with previous edits.
+func (op *setOp) record(start, end, n int, changed bool) {
+	if op.res.Matches == 0 {
+		op.res.Start, op.res.End = start, start+n
+	} else {
+		delta := n - (end - start)
+		if op.res.Start >= end {
+			op.res.Start += delta
+		} else if op.res.Start > start {
+			op.res.Start = start
+		}
+		if op.res.End >= end {
+			op.res.End += delta
+		} else if op.res.End < start+n {
+			op.res.End = start + n
+		}
+		if start < op.res.Start {
+			op.res.Start = start
+		}
+	}
+	op.res.Matches++
+	op.res.Changed = op.res.Changed || changed
+}
+
+// recordResult records an edit that another operation reported with r,
+// where delta is the change in length that it made to the json.
+func (op *setOp) recordResult(r Result, delta int) {
+	if r.Matches > 0 {
+		op.record(r.Start, r.End-delta, r.End-r.Start, r.Changed)
+	}
+}
+
+// recordBuf records an edit like record, where start and end are offsets
+// in the buffer that the new json is appended to.
+func (op *setOp) recordBuf(start, end, n int, changed bool) {
+	op.record(start-len(op.dst), end-len(op.dst), n, changed)
+}
+
+// equals returns true if raw is the json that op writes. It only works when
+// no characters of a stringified value need escaping.
+func (op *setOp) equals(raw string) bool {
+	if op.stringify {
+		return len(raw) == len(op.raw)+2 && raw[0] == '"' &&
+			raw[len(raw)-1] == '"' && raw[1:len(raw)-1] == op.raw
+	}
+	return raw == op.raw
+}
+
+// apply computes the value to set from the existing raw value when the
+// operation has an update function.
+func (op *setOp) apply(raw string) error {
+	if op.update == nil {
+		return nil
+	}
+	nraw, err := op.update(raw)
+	if err != nil {
+		return err
+	}
+	op.raw, op.stringify = nraw, false
+	return nil
+}
+
+// checkFound returns ErrConditionFailed when the existing raw value does
+// not meet the conditions of the operation.
+func (op *setOp) checkFound(raw string) error {
+	if op.onlyAbsent || (op.ifMatch != "" && raw != op.ifMatch) {
+		return ErrConditionFailed
+	}
+	return nil
+}
+
+// checkMissing returns ErrConditionFailed when the operation requires the
+// value to exist.
+func (op *setOp) checkMissing() error {
+	if op.onlyPresent || op.ifMatch != "" {
+		return ErrConditionFailed
+	}
+	return nil
+}
+
+// pathError returns an error for the current path component.
+func (op *setOp) pathError(paths []pathResult, err error) error {
+	return &PathError{Component: op.depth, Key: paths[0].part,
+		Offset: op.offset, Err: err}
+}
+
+// appendValue appends the value of the operation to buf.
+func (op *setOp) appendValue(buf []byte) []byte {
+	if op.stringify {
+		return appendStringify(buf, op.raw)
+	}
+	return append(buf, op.raw...)
+}
+
+func appendRawPaths(buf []byte, jstr string, paths []pathResult,
+	op *setOp) ([]byte, error) {
 	var err error
 	var res gjson.Result
 	var found bool
-	if del {
+	if op.del {
 		if paths[0].part == "-1" && !paths[0].force {
 			res = gjson.Get(jstr, "#")
 			if res.Int() > 0 {
@@ -266,43 +487,57 @@ func appendRawPaths(buf []byte, jstr string, paths []pathResult, raw string,
 	}
 	if res.Index > 0 {
 		if len(paths) > 1 {
+			if op.matchIndent {
+				op.style, _ = detectStyle(buf[len(op.dst):], jstr, op.style)
+			}
+			op.depth++
+			op.offset += res.Index
 			buf = append(buf, jstr[:res.Index]...)
-			buf, err = appendRawPaths(buf, res.Raw, paths[1:], raw,
-				stringify, del)
+			buf, err = appendRawPaths(buf, res.Raw, paths[1:], op)
 			if err != nil {
 				return nil, err
 			}
 			buf = append(buf, jstr[res.Index+len(res.Raw):]...)
 			return buf, nil
 		}
+		if err := op.checkFound(res.Raw); err != nil {
+			return nil, op.pathError(paths, err)
+		}
+		if err := op.apply(res.Raw); err != nil {
+			return nil, op.pathError(paths, err)
+		}
+		if op.del {
+			start, end := deleteSpan(jstr, res.Index, len(res.Raw))
+			buf = append(buf, jstr[:start]...)
+			op.recordBuf(len(buf), len(buf)+end-start, 0, true)
+			buf = append(buf, jstr[end:]...)
+			return buf, nil
+		}
+		prefix := buf
 		buf = append(buf, jstr[:res.Index]...)
-		var exidx int // additional forward stripping
-		if del {
-			var delNextComma bool
-			buf, delNextComma = deleteTailItem(buf)
-			if delNextComma {
-				i, j := res.Index+len(res.Raw), 0
-				for ; i < len(jstr); i, j = i+1, j+1 {
-					if jstr[i] <= ' ' {
-						continue
-					}
-					if jstr[i] == ',' {
-						exidx = j + 1
-					}
-					break
-				}
-			}
-		} else {
-			if stringify {
-				buf = appendStringify(buf, raw)
+		start := len(buf)
+		buf = op.appendValue(buf)
+		if op.insert && isArray(jstr) {
+			// shift the existing element to the right
+			if op.matchIndent {
+				st, _ := detectStyle(prefix[len(op.dst):], jstr, op.style)
+				buf = st.appendComma(buf)
 			} else {
-				buf = append(buf, raw...)
+				buf = append(buf, ',')
 			}
+			op.recordBuf(start, start, len(buf)-start, true)
+			buf = append(buf, jstr[res.Index:]...)
+			return buf, nil
 		}
-		buf = append(buf, jstr[res.Index+len(res.Raw)+exidx:]...)
+		op.recordBuf(start, start+len(res.Raw), len(buf)-start,
+			string(buf[start:]) != res.Raw)
+		buf = append(buf, jstr[res.Index+len(res.Raw):]...)
 		return buf, nil
 	}
-	if del {
+	if err := op.checkMissing(); err != nil {
+		return nil, op.pathError(paths, err)
+	}
+	if op.del {
 		return nil, errNoChange
 	}
 	n, numeric := atoui(paths[0])
@@ -342,7 +577,7 @@ func appendRawPaths(buf []byte, jstr string, paths []pathResult, raw string,
 	}
 	switch jsres.Raw[0] {
 	default:
-		return nil, &errorType{"json must be an object or array"}
+		return nil, op.pathError(paths, ErrNotContainer)
 	case '{':
 		end := len(jsres.Raw) - 1
 		for ; end > 0; end-- {
@@ -350,11 +585,28 @@ func appendRawPaths(buf []byte, jstr string, paths []pathResult, raw string,
 				break
 			}
 		}
+		if op.matchIndent {
+			st, last := detectStyle(buf, jsres.Raw[:end+1], op.style)
+			buf = append(buf, jsres.Raw[:last]...)
+			start := len(buf)
+			if comma {
+				buf = st.appendComma(buf)
+			} else {
+				buf = st.appendOpen(buf)
+			}
+			buf = appendBuild(buf, false, paths, op.raw, op.stringify, &st)
+			buf = st.appendClose(buf, jsres.Raw[last:end])
+			op.recordBuf(start, start+end-last, len(buf)-start, true)
+			buf = append(buf, '}')
+			return buf, nil
+		}
 		buf = append(buf, jsres.Raw[:end]...)
+		start := len(buf)
 		if comma {
 			buf = append(buf, ',')
 		}
-		buf = appendBuild(buf, false, paths, raw, stringify)
+		buf = appendBuild(buf, false, paths, op.raw, op.stringify, nil)
+		op.recordBuf(start, start, len(buf)-start, true)
 		buf = append(buf, '}')
 		return buf, nil
 	case '[':
@@ -363,25 +615,54 @@ func appendRawPaths(buf []byte, jstr string, paths []pathResult, raw string,
 			if paths[0].part == "-1" && !paths[0].force {
 				appendit = true
 			} else {
-				return nil, &errorType{
-					"cannot set array element for non-numeric key '" +
-						paths[0].part + "'"}
+				return nil, op.pathError(paths, ErrNonNumericKey)
 			}
 		}
+		if op.matchIndent {
+			end := len(jsres.Raw) - 1
+			for ; end > 0; end-- {
+				if jsres.Raw[end] == ']' {
+					break
+				}
+			}
+			st, last := detectStyle(buf, jsres.Raw[:end+1], op.style)
+			buf = append(buf, jsres.Raw[:last]...)
+			start := len(buf)
+			for i := len(gjson.Parse(jsres.Raw[:end+1]).Array()); ; i++ {
+				if comma {
+					buf = st.appendComma(buf)
+				} else {
+					buf = st.appendOpen(buf)
+					comma = true
+				}
+				if appendit || i >= n {
+					break
+				}
+				buf = append(buf, "null"...)
+			}
+			buf = appendBuild(buf, true, paths, op.raw, op.stringify, &st)
+			buf = st.appendClose(buf, jsres.Raw[last:end])
+			op.recordBuf(start, start+end-last, len(buf)-start, true)
+			buf = append(buf, ']')
+			return buf, nil
+		}
 		if appendit {
 			njson := trim(jsres.Raw)
 			if njson[len(njson)-1] == ']' {
 				njson = njson[:len(njson)-1]
 			}
 			buf = append(buf, njson...)
+			start := len(buf)
 			if comma {
 				buf = append(buf, ',')
 			}
 
-			buf = appendBuild(buf, true, paths, raw, stringify)
+			buf = appendBuild(buf, true, paths, op.raw, op.stringify, nil)
+			op.recordBuf(start, start, len(buf)-start, true)
 			buf = append(buf, ']')
 			return buf, nil
 		}
+		start := len(buf)
 		buf = append(buf, '[')
 		ress := jsres.Array()
 		for i := 0; i < len(ress); i++ {
@@ -398,12 +679,23 @@ func appendRawPaths(buf []byte, jstr string, paths []pathResult, raw string,
 				buf = append(buf, ',')
 			}
 		}
-		buf = appendBuild(buf, true, paths, raw, stringify)
+		buf = appendBuild(buf, true, paths, op.raw, op.stringify, nil)
 		buf = append(buf, ']')
+		op.recordBuf(start, start+len(jsres.Raw), len(buf)-start, true)
 		return buf, nil
 	}
 }
 
+// isArray returns true if the json is an array.
+func isArray(jstr string) bool {
+	for i := 0; i < len(jstr); i++ {
+		if jstr[i] > ' ' {
+			return jstr[i] == '['
+		}
+	}
+	return false
+}
+
 func isOptimisticPath(path string) bool {
 	for i := 0; i < len(path); i++ {
 		if path[i] < '.' || path[i] > 'z' {
@@ -462,11 +754,8 @@ func SetRaw(json, path, value string) (string, error) {
 // This furnction works the same as SetOptions except that the value is set
 // as a raw block of json. This allows for setting premarshalled json objects.
 func SetRawOptions(json, path, value string, opts *Options) (string, error) {
-	var optimistic bool
-	if opts != nil {
-		optimistic = opts.Optimistic
-	}
-	res, err := set(json, path, value, false, false, optimistic, false)
+	opts = stringOptions(opts)
+	res, err := set(json, path, &setOp{raw: value}, opts)
 	if err == errNoChange {
 		return json, nil
 	}
@@ -513,51 +802,40 @@ type sliceHeader struct {
 	cap  int
 }
 
-func set(jstr, path, raw string,
-	stringify, del, optimistic, inplace bool) ([]byte, error) {
-	if path == "" {
-		return []byte(jstr), &errorType{"path cannot be empty"}
+func set(jstr, path string, op *setOp, opts *Options) ([]byte, error) {
+	var optimistic, pointer bool
+	if opts != nil {
+		pointer = opts.Pointer
+		optimistic = opts.Optimistic
 	}
-	if !del && optimistic && isOptimisticPath(path) {
+	inplace := op.capacity > 0 && !op.appending
+	if path == "" && !pointer {
+		return []byte(jstr), &PathError{Component: -1, Offset: -1,
+			Err: ErrEmptyPath}
+	}
+	if opts != nil && opts.JSON5 {
+		res, err := setJSON5(jstr, path, op, opts)
+		return op.copyInPlace(jstr, res, err)
+	}
+	if opts != nil && opts.JSONC {
+		res, err := setJSONC(jstr, path, op, opts)
+		return op.copyInPlace(jstr, res, err)
+	}
+	if err := op.prepare(jstr, opts); err != nil {
+		return []byte(jstr), err
+	}
+	if pointer {
+		res, err := setPointer(jstr, path, op)
+		return op.copyInPlace(jstr, res, err)
+	}
+	if !op.del && !op.insert && optimistic && isOptimisticPath(path) {
 		res := gjson.Get(jstr, path)
 		if res.Exists() && res.Index > 0 {
-			sz := len(jstr) - len(res.Raw) + len(raw)
-			if stringify {
-				sz += 2
-			}
-			if inplace && sz <= len(jstr) {
-				if !stringify || !mustMarshalString(raw) {
-					jsonh := *(*stringHeader)(unsafe.Pointer(&jstr))
-					jsonbh := sliceHeader{
-						data: jsonh.data, len: jsonh.len, cap: jsonh.len}
-					jbytes := *(*[]byte)(unsafe.Pointer(&jsonbh))
-					if stringify {
-						jbytes[res.Index] = '"'
-						copy(jbytes[res.Index+1:], []byte(raw))
-						jbytes[res.Index+1+len(raw)] = '"'
-						copy(jbytes[res.Index+1+len(raw)+1:],
-							jbytes[res.Index+len(res.Raw):])
-					} else {
-						copy(jbytes[res.Index:], []byte(raw))
-						copy(jbytes[res.Index+len(raw):],
-							jbytes[res.Index+len(res.Raw):])
-					}
-					return jbytes[:sz], nil
-				}
-				return []byte(jstr), nil
-			}
-			buf := make([]byte, 0, sz)
-			buf = append(buf, jstr[:res.Index]...)
-			if stringify {
-				buf = appendStringify(buf, raw)
-			} else {
-				buf = append(buf, raw...)
-			}
-			buf = append(buf, jstr[res.Index+len(res.Raw):]...)
-			return buf, nil
+			return replaceValue(jstr, path, res.Index, res.Raw, op, inplace)
 		}
 	}
-	var paths []pathResult
+	var pathsArr [8]pathResult
+	paths := pathsArr[:0]
 	r, simple := parsePath(path)
 	if simple {
 		paths = append(paths, r)
@@ -570,31 +848,213 @@ func set(jstr, path, raw string,
 		}
 	}
 	if !simple {
-		if del {
-			return []byte(jstr),
-				&errorType{"cannot delete value from a complex path"}
+		if op.del {
+			res, err := deleteComplexPath(jstr, path, op)
+			return op.copyInPlace(jstr, res, err)
 		}
-		return setComplexPath(jstr, path, raw, stringify)
+		if op.insert {
+			return []byte(jstr), &PathError{Path: path, Component: -1,
+				Offset: -1, Err: ErrComplexPath}
+		}
+		res, err := setComplexPath(jstr, path, op, opts)
+		return op.copyInPlace(jstr, res, err)
+	}
+	if inplace {
+		if res, ok, err := setFoundInPlace(jstr, path, paths, op); ok {
+			return res, err
+		}
+	}
+	res, err := setSimplePath(jstr, path, paths, op)
+	return op.copyInPlace(jstr, res, err)
+}
+
+// inPlace enables editing the json in place when the ReplaceInPlace option
+// is set.
+func (op *setOp) inPlace(json []byte, opts *Options) {
+	if opts != nil && opts.ReplaceInPlace {
+		op.capacity = cap(json)
+	}
+}
+
+// inPlaceBytes returns the bytes of the json for editing in place, with a
+// length of n.
+func (op *setOp) inPlaceBytes(jstr string, n int) []byte {
+	jsonh := *(*stringHeader)(unsafe.Pointer(&jstr))
+	jsonbh := sliceHeader{data: jsonh.data, len: n, cap: op.capacity}
+	return *(*[]byte)(unsafe.Pointer(&jsonbh))
+}
+
+// spliceInPlace resizes jstr[start:end] to n bytes by moving the rest of
+// the json, returning the bytes of the json. The ok return value is false
+// if the new json does not fit in the capacity.
+func (op *setOp) spliceInPlace(jstr string, start, end, n int) ([]byte,
+	bool) {
+	size := len(jstr) - (end - start) + n
+	if size > op.capacity {
+		return nil, false
+	}
+	jbytes := op.inPlaceBytes(jstr, len(jstr))
+	if size > len(jstr) {
+		jbytes = jbytes[:size]
+	}
+	copy(jbytes[start+n:], jbytes[end:len(jstr)])
+	return jbytes[:size], true
+}
+
+// copyInPlace copies the new json back into the bytes of the original
+// json when editing in place and there is enough capacity.
+func (op *setOp) copyInPlace(jstr string, res []byte, err error) ([]byte,
+	error) {
+	if err != nil || op.capacity == 0 || op.appending ||
+		len(res) > op.capacity {
+		return res, err
+	}
+	jbytes := op.inPlaceBytes(jstr, len(res))
+	copy(jbytes, res)
+	return jbytes, nil
+}
+
+// setFoundInPlace replaces or deletes an existing value in place. The ok
+// return value is false if the value does not exist or the operation cannot
+// be performed in place.
+func setFoundInPlace(jstr, path string, paths []pathResult,
+	op *setOp) (res []byte, ok bool, err error) {
+	if op.insert {
+		return nil, false, nil
+	}
+	index, n, ok := locatePaths(jstr, paths, op.del)
+	if !ok {
+		return nil, false, nil
+	}
+	if !op.del {
+		res, err = replaceValue(jstr, path, index, jstr[index:index+n], op,
+			true)
+		return res, true, err
+	}
+	if err := op.checkFound(jstr[index : index+n]); err != nil {
+		return []byte(jstr), true, &PathError{Path: path,
+			Component: len(paths) - 1, Key: paths[len(paths)-1].part,
+			Offset: index, Err: err}
+	}
+	start, end := deleteSpan(jstr, index, n)
+	res, _ = op.spliceInPlace(jstr, start, end, 0)
+	op.record(start, end, 0, true)
+	return res, true, nil
+}
+
+// prepare copies the options into the operation, and validates the json
+// and the value in Strict mode.
+func (op *setOp) prepare(jstr string, opts *Options) error {
+	if opts == nil {
+		return nil
+	}
+	op.matchIndent = opts.MatchIndent
+	op.onlyAbsent = opts.OnlyIfAbsent
+	op.onlyPresent = opts.OnlyIfPresent
+	op.ifMatch = trim(opts.IfMatch)
+	if opts.Strict {
+		if trim(jstr) != "" {
+			if err := validate(jstr, false); err != nil {
+				return err
+			}
+		}
+		if !op.del && !op.stringify {
+			if err := validate(op.raw, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaceValue replaces the existing value at index with the value of the
+// operation. When inplace is true the bytes of the json are modified
+// directly if the new value fits in their capacity.
+func replaceValue(jstr, path string, index int, raw string, op *setOp,
+	inplace bool) ([]byte, error) {
+	err := op.checkFound(raw)
+	if err == nil {
+		err = op.apply(raw)
 	}
-	njson, err := appendRawPaths(nil, jstr, paths, raw, stringify, del)
 	if err != nil {
+		return []byte(jstr), &PathError{Path: path, Component: -1,
+			Offset: index, Err: err}
+	}
+	if inplace {
+		if op.stringify && mustMarshalString(op.raw) {
+			op.raw = string(appendStringify(nil, op.raw))
+			op.stringify = false
+		}
+		n := len(op.raw)
+		if op.stringify {
+			n += 2
+		}
+		changed := !op.equals(raw)
+		if jbytes, ok := op.spliceInPlace(jstr, index, index+len(raw),
+			n); ok {
+			op.appendValue(jbytes[index:index])
+			op.record(index, index+len(raw), n, changed)
+			return jbytes, nil
+		}
+	}
+	sz := len(jstr) - len(raw) + len(op.raw)
+	if op.stringify {
+		sz += 2
+	}
+	buf := op.dst
+	if buf == nil {
+		buf = make([]byte, 0, sz)
+	}
+	base := len(buf)
+	buf = append(buf, jstr[:index]...)
+	buf = op.appendValue(buf)
+	op.record(index, index+len(raw), len(buf)-base-index,
+		string(buf[base+index:]) != raw)
+	buf = append(buf, jstr[index+len(raw):]...)
+	return buf, nil
+}
+
+// setSimplePath performs the operation on the value for the components of
+// a simple path.
+func setSimplePath(jstr, path string, paths []pathResult,
+	op *setOp) ([]byte, error) {
+	njson, err := appendRawPaths(op.dst, jstr, paths, op)
+	if err != nil {
+		if perr, ok := err.(*PathError); ok {
+			perr.Path = path
+		}
 		return []byte(jstr), err
 	}
 	return njson, nil
 }
 
-func setComplexPath(jstr, path, raw string, stringify bool) ([]byte, error) {
+func setComplexPath(jstr, path string, op *setOp,
+	opts *Options) ([]byte, error) {
+	orig := jstr
 	res := gjson.Get(jstr, path)
 	if !res.Exists() || !(res.Index != 0 || len(res.Indexes) != 0) {
+		if err := op.checkMissing(); err != nil {
+			return []byte(jstr), &PathError{Path: path, Component: -1,
+				Offset: -1, Err: err}
+		}
+		if opts != nil && opts.Upsert {
+			return upsertQuery(jstr, path, op, opts)
+		}
 		return []byte(jstr), errNoChange
 	}
+	if err := checkMatches(res, op); err != nil {
+		return []byte(jstr), &PathError{Path: path, Component: -1,
+			Offset: -1, Err: err}
+	}
 	if res.Index != 0 {
-		njson := []byte(jstr[:res.Index])
-		if stringify {
-			njson = appendStringify(njson, raw)
-		} else {
-			njson = append(njson, raw...)
+		if err := op.apply(res.Raw); err != nil {
+			return []byte(jstr), &PathError{Path: path, Component: -1,
+				Offset: res.Index, Err: err}
 		}
+		njson := []byte(jstr[:res.Index])
+		njson = op.appendValue(njson)
+		op.record(res.Index, res.Index+len(res.Raw), len(njson)-res.Index,
+			string(njson[res.Index:]) != res.Raw)
 		njson = append(njson, jstr[res.Index+len(res.Raw):]...)
 		jstr = string(njson)
 	}
@@ -620,107 +1080,490 @@ func setComplexPath(jstr, path, raw string, stringify bool) ([]byte, error) {
 		for _, val := range vals {
 			vres := val.res
 			index := val.index
-			njson := []byte(jstr[:index])
-			if stringify {
-				njson = appendStringify(njson, raw)
-			} else {
-				njson = append(njson, raw...)
+			if err := op.apply(vres.Raw); err != nil {
+				return []byte(orig), &PathError{Path: path, Component: -1,
+					Offset: index, Err: err}
 			}
+			njson := []byte(jstr[:index])
+			njson = op.appendValue(njson)
+			op.record(index, index+len(vres.Raw), len(njson)-index,
+				string(njson[index:]) != vres.Raw)
 			njson = append(njson, jstr[index+len(vres.Raw):]...)
 			jstr = string(njson)
 		}
 	}
-	return []byte(jstr), nil
+	return append(op.dst, jstr...), nil
 }
 
-// SetOptions sets a json value for the specified path with options.
-// A path is in dot syntax, such as "name.last" or "age".
-// This function expects that the json is well-formed, and does not validate.
-// Invalid json will not panic, but it may return back unexpected results.
-// An error is returned if the path is not valid.
-func SetOptions(json, path string, value interface{},
-	opts *Options) (string, error) {
-	if opts != nil {
-		if opts.ReplaceInPlace {
-			// it's not safe to replace bytes in-place for strings
-			// copy the Options and set options.ReplaceInPlace to false.
-			nopts := *opts
-			opts = &nopts
-			opts.ReplaceInPlace = false
-		}
+// checkMatches checks the conditions of the operation against all of the
+// values that match a complex path.
+func checkMatches(res gjson.Result, op *setOp) error {
+	if len(res.Indexes) == 0 {
+		return op.checkFound(res.Raw)
 	}
-	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
-	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
-	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
-	res, err := SetBytesOptions(jsonb, path, value, opts)
-	return string(res), err
+	var err error
+	res.ForEach(func(_, vres gjson.Result) bool {
+		err = op.checkFound(vres.Raw)
+		return err == nil
+	})
+	return err
 }
 
-// SetBytesOptions sets a json value for the specified path with options.
-// If working with bytes, this method preferred over
-// SetOptions(string(data), path, value)
-func SetBytesOptions(json []byte, path string, value interface{},
-	opts *Options) ([]byte, error) {
-	var optimistic, inplace bool
-	if opts != nil {
-		optimistic = opts.Optimistic
-		inplace = opts.ReplaceInPlace
-	}
-	jstr := *(*string)(unsafe.Pointer(&json))
-	var res []byte
-	var err error
+// rawValue converts a Go value into the operation that is passed to set.
+// Common types are converted directly, and other types are marshaled using
+// the encoding/json package.
+func rawValue(value interface{}) (setOp, error) {
 	switch v := value.(type) {
 	default:
-		b, merr := jsongo.Marshal(value)
-		if merr != nil {
-			return nil, merr
-		}
-		raw := *(*string)(unsafe.Pointer(&b))
-		res, err = set(jstr, path, raw, false, false, optimistic, inplace)
+		return marshalValue(value)
 	case dtype:
-		res, err = set(jstr, path, "", false, true, optimistic, inplace)
+		return setOp{del: true}, nil
 	case string:
-		res, err = set(jstr, path, v, true, false, optimistic, inplace)
+		return setOp{raw: v, stringify: true}, nil
 	case []byte:
-		raw := *(*string)(unsafe.Pointer(&v))
-		res, err = set(jstr, path, raw, true, false, optimistic, inplace)
+		return setOp{raw: *(*string)(unsafe.Pointer(&v)), stringify: true}, nil
 	case bool:
 		if v {
-			res, err = set(jstr, path, "true", false, false, optimistic, inplace)
-		} else {
-			res, err = set(jstr, path, "false", false, false, optimistic, inplace)
+			return setOp{raw: "true"}, nil
 		}
+		return setOp{raw: "false"}, nil
+	case int:
+		return setOp{raw: strconv.FormatInt(int64(v), 10)}, nil
 	case int8:
-		res, err = set(jstr, path, strconv.FormatInt(int64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatInt(int64(v), 10)}, nil
 	case int16:
-		res, err = set(jstr, path, strconv.FormatInt(int64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatInt(int64(v), 10)}, nil
 	case int32:
-		res, err = set(jstr, path, strconv.FormatInt(int64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatInt(int64(v), 10)}, nil
 	case int64:
-		res, err = set(jstr, path, strconv.FormatInt(int64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatInt(int64(v), 10)}, nil
+	case uint:
+		return setOp{raw: strconv.FormatUint(uint64(v), 10)}, nil
 	case uint8:
-		res, err = set(jstr, path, strconv.FormatUint(uint64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatUint(uint64(v), 10)}, nil
 	case uint16:
-		res, err = set(jstr, path, strconv.FormatUint(uint64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatUint(uint64(v), 10)}, nil
 	case uint32:
-		res, err = set(jstr, path, strconv.FormatUint(uint64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatUint(uint64(v), 10)}, nil
 	case uint64:
-		res, err = set(jstr, path, strconv.FormatUint(uint64(v), 10),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatUint(uint64(v), 10)}, nil
 	case float32:
-		res, err = set(jstr, path, strconv.FormatFloat(float64(v), 'f', -1, 64),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatFloat(float64(v), 'f', -1, 64)}, nil
 	case float64:
-		res, err = set(jstr, path, strconv.FormatFloat(float64(v), 'f', -1, 64),
-			false, false, optimistic, inplace)
+		return setOp{raw: strconv.FormatFloat(float64(v), 'f', -1, 64)}, nil
+	case jsongo.RawMessage:
+		if len(v) == 0 {
+			return setOp{raw: "null"}, nil
+		}
+		return setOp{raw: *(*string)(unsafe.Pointer(&v))}, nil
+	case jsongo.Number:
+		if v == "" {
+			return setOp{raw: "0"}, nil
+		}
+		if !isNumber(string(v)) {
+			return setOp{}, &PathError{Component: -1, Offset: -1,
+				Err: ErrInvalidValue}
+		}
+		return setOp{raw: string(v)}, nil
+	case gjson.Result:
+		if !v.Exists() {
+			return setOp{raw: "null"}, nil
+		}
+		return setOp{raw: v.Raw}, nil
+	case time.Time:
+		return setOp{raw: v.Format(time.RFC3339Nano), stringify: true}, nil
+	case *string:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *bool:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *int:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *int8:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *int16:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *int32:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *int64:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *uint:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *uint8:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *uint16:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *uint32:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *uint64:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *float32:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case *float64:
+		if v == nil {
+			return setOp{raw: "null"}, nil
+		}
+		return rawValue(*v)
+	case jsongo.Marshaler:
+		if isNilPointer(v) {
+			return setOp{raw: "null"}, nil
+		}
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return setOp{}, err
+		}
+		raw := trim(*(*string)(unsafe.Pointer(&b)))
+		if validate(raw, true) != nil {
+			return setOp{}, &PathError{Component: -1, Offset: -1,
+				Err: ErrInvalidValue}
+		}
+		return setOp{raw: raw}, nil
+	case encoding.TextMarshaler:
+		if isNilPointer(v) {
+			return setOp{raw: "null"}, nil
+		}
+		b, err := v.MarshalText()
+		if err != nil {
+			return setOp{}, err
+		}
+		return setOp{raw: *(*string)(unsafe.Pointer(&b)), stringify: true}, nil
+	}
+}
+
+// isNilPointer returns true if the value is a nil pointer, which is null in
+// json without calling its methods.
+func isNilPointer(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// marshalValue marshals a value using the encoding/json package.
+func marshalValue(value interface{}) (setOp, error) {
+	b, err := jsongo.Marshal(value)
+	if err != nil {
+		return setOp{}, err
+	}
+	return setOp{raw: *(*string)(unsafe.Pointer(&b))}, nil
+}
+
+// hasWildcard returns true if the path has a '*' or '?' wildcard character
+// outside of a query.
+func hasWildcard(path string) bool {
+	var depth int
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case '"':
+			if depth > 0 {
+				for i++; i < len(path) && path[i] != '"'; i++ {
+					if path[i] == '\\' {
+						i++
+					}
+				}
+			}
+		case '*', '?':
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deleteComplexPath deletes the values that match a path which has queries
+// or wildcards. All elements matched by a "#(...)#" query are deleted, and a
+// path with wildcards deletes every matching value.
+func deleteComplexPath(jstr, path string, op *setOp) ([]byte, error) {
+	wild := hasWildcard(path)
+	// A wildcard deletes one match at a time, so a failed condition on a
+	// later match must not leave the earlier ones deleted.
+	orig, ores := jstr, op.res
+	var changed bool
+	for {
+		res := gjson.Get(jstr, path)
+		if !res.Exists() || !(res.Index != 0 || len(res.Indexes) != 0) {
+			break
+		}
+		if err := checkMatches(res, op); err != nil {
+			op.res = ores
+			return []byte(orig), &PathError{Path: path, Component: -1,
+				Offset: -1, Err: err}
+		}
+		if res.Index != 0 {
+			start, end := deleteSpan(jstr, res.Index, len(res.Raw))
+			jstr = jstr[:start] + jstr[end:]
+			op.record(start, end, 0, true)
+			changed = true
+			if wild {
+				continue
+			}
+			break
+		}
+		type val struct {
+			index int
+			res   gjson.Result
+		}
+		vals := make([]val, 0, len(res.Indexes))
+		res.ForEach(func(_, vres gjson.Result) bool {
+			vals = append(vals, val{res: vres})
+			return true
+		})
+		if len(res.Indexes) != len(vals) {
+			break
+		}
+		for i := 0; i < len(res.Indexes); i++ {
+			vals[i].index = res.Indexes[i]
+		}
+		sort.SliceStable(vals, func(i, j int) bool {
+			return vals[i].index > vals[j].index
+		})
+		for _, val := range vals {
+			start, end := deleteSpan(jstr, val.index, len(val.res.Raw))
+			jstr = jstr[:start] + jstr[end:]
+			op.record(start, end, 0, true)
+		}
+		changed = true
+		break
+	}
+	if !changed {
+		if err := op.checkMissing(); err != nil {
+			return []byte(jstr), &PathError{Path: path, Component: -1,
+				Offset: -1, Err: err}
+		}
+		return []byte(jstr), errNoChange
+	}
+	return append(op.dst, jstr...), nil
+}
+
+// parseUpsertQuery splits a path in the form "prefix.#(key==value).rest"
+// into its parts. The error is errNoChange when the path has no query, and
+// ErrComplexPath when it has a query that is not in that form.
+func parseUpsertQuery(path string) (prefix, key, value, rest string,
+	err error) {
+	start := -1
+	simple := true
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' {
+			i++
+			continue
+		}
+		if path[i] == '#' && i+1 < len(path) && path[i+1] == '(' &&
+			(i == 0 || path[i-1] == '.') {
+			start = i
+			break
+		}
+		if !isSimpleChar(path[i]) {
+			simple = false
+		}
+	}
+	if start == -1 {
+		return "", "", "", "", errNoChange
+	}
+	unsupported := &PathError{Path: path, Component: -1, Offset: -1,
+		Err: ErrComplexPath}
+	if !simple {
+		return "", "", "", "", unsupported
+	}
+	end, depth := -1, 0
+	for i := start + 1; i < len(path) && end == -1; i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		case '"':
+			for i++; i < len(path) && path[i] != '"'; i++ {
+				if path[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	if end == -1 {
+		return "", "", "", "", unsupported
+	}
+	if start > 0 {
+		prefix = path[:start-1]
+	}
+	query := path[start+2 : end]
+	i := end + 1
+	if i < len(path) && path[i] == '#' {
+		i++
+	}
+	if i < len(path) {
+		if path[i] != '.' {
+			return "", "", "", "", unsupported
+		}
+		rest = path[i+1:]
+	}
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '"':
+			return "", "", "", "", unsupported
+		case '!', '<', '>', '%':
+			return "", "", "", "", unsupported
+		case '=':
+			key, value = trim(query[:i]), query[i+1:]
+			if len(value) > 0 && value[0] == '=' {
+				value = value[1:]
+			}
+			value = trim(value)
+			if !gjson.Valid(value) {
+				return "", "", "", "", unsupported
+			}
+			return prefix, key, value, rest, nil
+		}
+	}
+	return "", "", "", "", unsupported
+}
+
+// upsertElement makes the value of op, which is appended as a new element,
+// match the query, so that the element is found by the query again. The
+// value must be an object, which the key and value of the query are merged
+// into, or for a query without a key, equal to the value of the query.
+func upsertElement(path, key, value string, op *setOp) ([]byte, error) {
+	elem := op.appendValue(nil)
+	if op.update != nil {
+		return elem, nil
+	}
+	res := gjson.ParseBytes(elem)
+	switch {
+	case key == "":
+		if !jsonEqual(res, gjson.Parse(value)) {
+			return nil, &PathError{Path: path, Component: -1, Offset: -1,
+				Err: ErrInvalidValue}
+		}
+		return elem, nil
+	case !res.IsObject():
+		return nil, &PathError{Path: path, Component: -1, Offset: -1,
+			Err: ErrNotObject}
+	}
+	return set(string(elem), key, &setOp{raw: value}, nil)
+}
+
+// upsertQuery appends a new element to the array that is queried by path.
+// The element is an object with the key and value of the query, plus the
+// remaining path set to the value of op.
+func upsertQuery(jstr, path string, op *setOp,
+	opts *Options) ([]byte, error) {
+	prefix, key, value, rest, err := parseUpsertQuery(path)
+	if err != nil {
+		return []byte(jstr), err
+	}
+	if _, ok := parseSimplePath(prefix); !ok && prefix != "" {
+		return []byte(jstr), &PathError{Path: path, Component: -1,
+			Offset: -1, Err: ErrComplexPath}
 	}
+	var elem []byte
+	if rest == "" {
+		elem, err = upsertElement(path, key, value, op)
+		if err != nil {
+			return []byte(jstr), err
+		}
+	} else if key == "" {
+		return []byte(jstr), &PathError{Path: path, Component: -1,
+			Offset: -1, Err: ErrComplexPath}
+	} else {
+		elem, err = set("{}", key, &setOp{raw: value}, nil)
+		if err != nil {
+			return []byte(jstr), err
+		}
+		dst, capacity := op.dst, op.capacity
+		op.dst, op.capacity = nil, 0
+		elem, err = set(string(elem), rest, op, nil)
+		op.dst, op.capacity = dst, capacity
+		if err != nil {
+			return []byte(jstr), err
+		}
+	}
+	if prefix == "" {
+		prefix = "-1"
+	} else {
+		prefix += ".-1"
+	}
+	eop := setOp{raw: string(elem), dst: op.dst}
+	njson, err := set(jstr, prefix, &eop, opts)
+	op.res = eop.res
+	return njson, err
+}
+
+// SetOptions sets a json value for the specified path with options.
+// A path is in dot syntax, such as "name.last" or "age".
+// This function expects that the json is well-formed, and does not validate.
+// Invalid json will not panic, but it may return back unexpected results.
+// An error is returned if the path is not valid.
+func SetOptions(json, path string, value interface{},
+	opts *Options) (string, error) {
+	opts = stringOptions(opts)
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, err := SetBytesOptions(jsonb, path, value, opts)
+	return string(res), err
+}
+
+// SetBytesOptions sets a json value for the specified path with options.
+// If working with bytes, this method preferred over
+// SetOptions(string(data), path, value)
+func SetBytesOptions(json []byte, path string, value interface{},
+	opts *Options) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	op, err := rawValue(value)
+	if err != nil {
+		return nil, err
+	}
+	op.inPlace(json, opts)
+	res, err := set(jstr, path, &op, opts)
 	if err == errNoChange {
 		return json, nil
 	}
@@ -734,14 +1577,146 @@ func SetRawBytesOptions(json []byte, path string, value []byte,
 	opts *Options) ([]byte, error) {
 	jstr := *(*string)(unsafe.Pointer(&json))
 	vstr := *(*string)(unsafe.Pointer(&value))
-	var optimistic, inplace bool
-	if opts != nil {
-		optimistic = opts.Optimistic
-		inplace = opts.ReplaceInPlace
+	op := setOp{raw: vstr}
+	op.inPlace(json, opts)
+	res, err := set(jstr, path, &op, opts)
+	if err == errNoChange {
+		return json, nil
 	}
-	res, err := set(jstr, path, vstr, false, false, optimistic, inplace)
+	return res, err
+}
+
+// Insert inserts a json value into an array at the specified path.
+// The path must end with the index of the array element that the value is
+// inserted in front of. The existing element and all elements after it are
+// shifted to the right. Inserting at index 0 prepends the value to the
+// array, while an index that is past the end of the array, or the -1 key,
+// appends the value like Set does.
+//
+//	Insert(`{"children":["Sara","Jack"]}`, "children.1", "Alex")
+//	>> {"children":["Sara","Alex","Jack"]}
+//
+// When the path refers to an object member, the value is set as by Set.
+func Insert(json, path string, value interface{}) (string, error) {
+	return InsertOptions(json, path, value, nil)
+}
+
+// InsertOptions inserts a json value into an array at the specified path
+// with options.
+func InsertOptions(json, path string, value interface{},
+	opts *Options) (string, error) {
+	opts = stringOptions(opts)
+	jsonh := *(*stringHeader)(unsafe.Pointer(&json))
+	jsonbh := sliceHeader{data: jsonh.data, len: jsonh.len, cap: jsonh.len}
+	jsonb := *(*[]byte)(unsafe.Pointer(&jsonbh))
+	res, err := InsertBytesOptions(jsonb, path, value, opts)
+	return string(res), err
+}
+
+// InsertBytes inserts a json value into an array at the specified path.
+// If working with bytes, this method preferred over
+// Insert(string(data), path, value)
+func InsertBytes(json []byte, path string, value interface{}) ([]byte, error) {
+	return InsertBytesOptions(json, path, value, nil)
+}
+
+// InsertBytesOptions inserts a json value into an array at the specified
+// path with options. If working with bytes, this method preferred over
+// InsertOptions(string(data), path, value, opts)
+func InsertBytesOptions(json []byte, path string, value interface{},
+	opts *Options) ([]byte, error) {
+	op, err := rawValue(value)
+	if err != nil {
+		return nil, err
+	}
+	op.insert = true
+	jstr := *(*string)(unsafe.Pointer(&json))
+	op.inPlace(json, opts)
+	res, err := set(jstr, path, &op, opts)
+	if err == errNoChange {
+		return json, nil
+	}
+	return res, err
+}
+
+// InsertRaw inserts a raw json value into an array at the specified path.
+// This function works the same as Insert except that the value is set as a
+// raw block of json.
+func InsertRaw(json, path, value string) (string, error) {
+	return InsertRawOptions(json, path, value, nil)
+}
+
+// InsertRawOptions inserts a raw json value into an array at the specified
+// path with options.
+func InsertRawOptions(json, path, value string, opts *Options) (string,
+	error) {
+	opts = stringOptions(opts)
+	res, err := set(json, path, &setOp{raw: value, insert: true}, opts)
+	if err == errNoChange {
+		return json, nil
+	}
+	return string(res), err
+}
+
+// InsertRawBytes inserts a raw json value into an array at the specified
+// path. If working with bytes, this method preferred over
+// InsertRaw(string(data), path, value)
+func InsertRawBytes(json []byte, path string, value []byte) ([]byte, error) {
+	return InsertRawBytesOptions(json, path, value, nil)
+}
+
+// InsertRawBytesOptions inserts a raw json value into an array at the
+// specified path with options. If working with bytes, this method preferred
+// over InsertRawOptions(string(data), path, value, opts)
+func InsertRawBytesOptions(json []byte, path string, value []byte,
+	opts *Options) ([]byte, error) {
+	jstr := *(*string)(unsafe.Pointer(&json))
+	vstr := *(*string)(unsafe.Pointer(&value))
+	op := setOp{raw: vstr, insert: true}
+	op.inPlace(json, opts)
+	res, err := set(jstr, path, &op, opts)
 	if err == errNoChange {
 		return json, nil
 	}
 	return res, err
 }
+
+// Prepend inserts a json value at the start of the array at the specified
+// path. An array is created when the path does not exist.
+//
+//	Prepend(`{"children":["Sara","Jack"]}`, "children", "Alex")
+//	>> {"children":["Alex","Sara","Jack"]}
+func Prepend(json, path string, value interface{}) (string, error) {
+	return Insert(json, firstElementPath(path), value)
+}
+
+// PrependBytes inserts a json value at the start of the array at the
+// specified path. If working with bytes, this method preferred over
+// Prepend(string(data), path, value)
+func PrependBytes(json []byte, path string, value interface{}) ([]byte,
+	error) {
+	return InsertBytes(json, firstElementPath(path), value)
+}
+
+// PrependRaw inserts a raw json value at the start of the array at the
+// specified path.
+func PrependRaw(json, path, value string) (string, error) {
+	return InsertRaw(json, firstElementPath(path), value)
+}
+
+// PrependRawBytes inserts a raw json value at the start of the array at the
+// specified path. If working with bytes, this method preferred over
+// PrependRaw(string(data), path, value)
+func PrependRawBytes(json []byte, path string, value []byte) ([]byte,
+	error) {
+	return InsertRawBytes(json, firstElementPath(path), value)
+}
+
+// firstElementPath returns the path to the first element of the array at
+// path. An empty path refers to the root array.
+func firstElementPath(path string) string {
+	if path == "" {
+		return "0"
+	}
+	return path + ".0"
+}
@@ -2,8 +2,11 @@ package sjson
 
 import (
 	"encoding/hex"
+	jsongo "encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net"
 	"testing"
 	"time"
 
@@ -351,3 +354,333 @@ func TestIssue61(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestInsert(t *testing.T) {
+	tests := []struct {
+		json, path, value, expect string
+	}{
+		{`{"a":[1,2,3]}`, "a.0", "0", `{"a":[0,1,2,3]}`},
+		{`{"a":[1,2,3]}`, "a.2", "0", `{"a":[1,2,0,3]}`},
+		{`{"a":[1,2,3]}`, "a.3", "0", `{"a":[1,2,3,0]}`},
+		{`{"a":[1,2,3]}`, "a.-1", "0", `{"a":[1,2,3,0]}`},
+		{`{"a":[1,2,3]}`, "a.5", "0", `{"a":[1,2,3,null,null,0]}`},
+		{`{"a":[ 1, 2 ]}`, "a.1", `{"b":1}`, `{"a":[ 1, {"b":1},2 ]}`},
+		{`{"a":[]}`, "a.0", "0", `{"a":[0]}`},
+		{`{}`, "a.0", "0", `{"a":[0]}`},
+		{`{"a":[[1],[2]]}`, "a.1.0", "0", `{"a":[[1],[0,2]]}`},
+		{`[1]`, "0", "0", `[0,1]`},
+		{`{"a":{"0":1}}`, "a.0", "0", `{"a":{"0":0}}`},
+	}
+	for i, tt := range tests {
+		res, err := InsertRaw(tt.json, tt.path, tt.value)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+		resb, err := InsertRawBytes([]byte(tt.json), tt.path,
+			[]byte(tt.value))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if string(resb) != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, resb)
+		}
+	}
+	json, err := Insert(`{"children":["Sara","Jack"]}`, "children.1", "Alex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json != `{"children":["Sara","Alex","Jack"]}` {
+		t.Fatalf("got '%v'", json)
+	}
+	json, err = Prepend(json, "children", "Tom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json != `{"children":["Tom","Sara","Alex","Jack"]}` {
+		t.Fatalf("got '%v'", json)
+	}
+	json, err = PrependRaw(`[1]`, "", `0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json != `[0,1]` {
+		t.Fatalf("got '%v'", json)
+	}
+	if _, err := Insert(example, "friends.#.nets.0", "fb"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestDeleteComplexPath(t *testing.T) {
+	tests := []struct {
+		json, path, expect string
+	}{
+		{`{"friends":[{"age":44},{"age":68},{"age":30},{"age":47}]}`,
+			`friends.#(age>40)#`, `{"friends":[{"age":30}]}`},
+		{`{"friends":[{"age":44},{"age":68},{"age":30},{"age":47}]}`,
+			`friends.#(age>40)`, `{"friends":[{"age":68},{"age":30},{"age":47}]}`},
+		{`{"friends":[{"age":44},{"age":30}]}`, `friends.#(age>50)#`,
+			`{"friends":[{"age":44},{"age":30}]}`},
+		{`{"friends":[{"a":1,"b":2},{"a":3}]}`, `friends.#.a`,
+			`{"friends":[{"b":2},{}]}`},
+		{`{"key1":1,"key2":2,"other":3,"key3":4}`, `key*`, `{"other":3}`},
+		{`{"a":{"x1":1,"y":2,"x2":3}}`, `a.x?`, `{"a":{"y":2}}`},
+		{`{"a":[1,2,3]}`, `a.#(==2)#`, `{"a":[1,3]}`},
+		{`{"a":[1,2,3]}`, `a.#(>0)#`, `{"a":[]}`},
+		{`{"a":[{"n":"x*"},{"n":"y"}]}`, `a.#(n=="x*")`, `{"a":[{"n":"y"}]}`},
+	}
+	for i, tt := range tests {
+		res, err := Delete(tt.json, tt.path)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+	}
+	json, err := Delete(example, `friends.#(nets.#(=="fb"))#`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gjson.Get(json, "friends.#.first").String() != `["Jane"]` {
+		t.Fatalf("got '%v'", json)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	opts := &Options{Upsert: true}
+	tests := []struct {
+		json, path, value, expect string
+	}{
+		{`{"friends":[{"first":"Tom","age":1}]}`, `friends.#(first=="Bob").age`,
+			`2`, `{"friends":[{"first":"Tom","age":1},{"first":"Bob","age":2}]}`},
+		{`{"friends":[{"first":"Bob","age":1}]}`, `friends.#(first=="Bob").age`,
+			`2`, `{"friends":[{"first":"Bob","age":2}]}`},
+		{`{}`, `friends.#(id=7)#.tags.0`, `"x"`,
+			`{"friends":[{"id":7,"tags":["x"]}]}`},
+		{`[]`, `#(name.first=="Bob").age`, `2`,
+			`[{"name":{"first":"Bob"},"age":2}]`},
+		{`{"a":[1]}`, `a.#(==2)`, `2`, `{"a":[1,2]}`},
+		{`{"f":[]}`, `f.#(id==3)`, `{"name":"Sara"}`,
+			`{"f":[{"name":"Sara","id":3}]}`},
+		{`{"f":[{"name":"Sara","id":3}]}`, `f.#(id==3)`, `{"name":"Andy"}`,
+			`{"f":[{"name":"Andy"}]}`},
+		{`{"f":[{"id":3}]}`, `f.#(id==3)`, `{"a":1,"id":4}`,
+			`{"f":[{"a":1,"id":4}]}`},
+	}
+	for i, tt := range tests {
+		res, err := SetRawOptions(tt.json, tt.path, tt.value, opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, res)
+		}
+	}
+	for _, value := range []string{`5`, `[1]`, `"x"`} {
+		_, err := SetRawOptions(`{"f":[]}`, `f.#(id==3)`, value, opts)
+		if !errors.Is(err, ErrNotObject) {
+			t.Fatalf("expected ErrNotObject, got %v", err)
+		}
+	}
+	_, err := SetRawOptions(`{"a":[1]}`, `a.#(==2)`, `3`, opts)
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+	for _, path := range []string{`a.#(id>1).x`, `a.#(id!=1).x`,
+		`a.#(id==2&&b==1).x`, `a.#(name==Bob).x`, `a.#(==2).x`,
+		`a*.#(id==2).x`} {
+		json, err := SetRawOptions(`{"a":[{"id":1}]}`, path, `2`, opts)
+		if !errors.Is(err, ErrComplexPath) || json != `{"a":[{"id":1}]}` {
+			t.Fatalf("%s: expected ErrComplexPath, got '%v', %v", path, json,
+				err)
+		}
+	}
+	json, err := SetOptions(example, `friends.#(first=="Bob").last`, "Smith",
+		opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gjson.Get(json, `friends.3`).String() !=
+		`{"first":"Bob","last":"Smith"}` {
+		t.Fatalf("got '%v'", json)
+	}
+}
+
+func TestConditionalSet(t *testing.T) {
+	tests := []struct {
+		json, path, value string
+		opts              Options
+		expect            string
+		failed            bool
+	}{
+		{`{"a":1}`, "b", `2`, Options{OnlyIfAbsent: true},
+			`{"a":1,"b":2}`, false},
+		{`{"a":1}`, "a", `2`, Options{OnlyIfAbsent: true},
+			`{"a":1}`, true},
+		{`{"a":1}`, "a", `2`, Options{OnlyIfAbsent: true, Optimistic: true},
+			`{"a":1}`, true},
+		{`{"a":1}`, "a", `2`, Options{OnlyIfPresent: true},
+			`{"a":2}`, false},
+		{`{"a":1}`, "b.c", `2`, Options{OnlyIfPresent: true},
+			`{"a":1}`, true},
+		{`{"a":[1]}`, "a.1", `2`, Options{OnlyIfPresent: true},
+			`{"a":[1]}`, true},
+		{`{"a":{"v":1}}`, "a", `{"v":2}`, Options{IfMatch: ` {"v":1} `},
+			`{"a":{"v":2}}`, false},
+		{`{"a":{"v":1}}`, "a", `{"v":2}`, Options{IfMatch: `{"v":3}`},
+			`{"a":{"v":1}}`, true},
+		{`{"a":1}`, "b", `2`, Options{IfMatch: `1`},
+			`{"a":1}`, true},
+		{`{"a":[1,2]}`, "a.#(==1)", `3`, Options{IfMatch: `1`},
+			`{"a":[3,2]}`, false},
+		{`{"a":[1,2]}`, "a.#(>0)#", `3`, Options{IfMatch: `1`},
+			`{"a":[1,2]}`, true},
+		{`{"a":[1,2]}`, "a.#(==3)", `3`,
+			Options{OnlyIfPresent: true, Upsert: true}, `{"a":[1,2]}`, true},
+	}
+	for i, tt := range tests {
+		opts := tt.opts
+		json, err := SetRawOptions(tt.json, tt.path, tt.value, &opts)
+		if tt.failed != errors.Is(err, ErrConditionFailed) {
+			t.Fatalf("test %d: unexpected error %v", i, err)
+		}
+		if tt.failed {
+			json = tt.json
+		}
+		if json != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect, json)
+		}
+	}
+	opts := &Options{IfMatch: `1`}
+	json, err := DeleteOptions(`{"a":1,"b":2}`, "a", opts)
+	if err != nil || json != `{"b":2}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	_, err = DeleteOptions(`{"a":1,"b":2}`, "b", opts)
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Fatal("expected an error")
+	}
+	_, err = DeleteOptions(`{"a":[2]}`, "a.#(==1)", opts)
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Fatal("expected an error")
+	}
+	json, err = DeleteOptions(`{"ab":1,"ac":2}`, "a*", opts)
+	if !errors.Is(err, ErrConditionFailed) || json != `{"ab":1,"ac":2}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+}
+
+func TestReplaceInPlace(t *testing.T) {
+	tests := []struct {
+		json, path string
+		value      interface{}
+		extra      int
+		expect     string
+		inplace    bool
+	}{
+		{`{"a":"hello","b":2}`, "a", "hi", 0, `{"a":"hi","b":2}`, true},
+		{`{"a":"hi","b":2}`, "a", "hello", 3, `{"a":"hello","b":2}`, true},
+		{`{"a":"hi","b":2}`, "a", "hello", 2, `{"a":"hello","b":2}`, false},
+		{`{"a":"hi","b":2}`, "a", "x\"y\n", 4, `{"a":"x\"y\n","b":2}`, true},
+		{`{"a":{"b":[1,2,3]}}`, "a.b.1", dtype{}, 0, `{"a":{"b":[1,3]}}`, true},
+		{`{"a":1,"b":2}`, "a", dtype{}, 0, `{"b":2}`, true},
+		{`{"a":1}`, "b", true, 9, `{"a":1,"b":true}`, true},
+		{`{"a":1}`, "b", true, 8, `{"a":1,"b":true}`, false},
+		{`{"a":[1,2,1]}`, "a.#(==1)#", 10, 2, `{"a":[10,2,10]}`, true},
+		{`{"a":[1,2,1]}`, "a.#(==1)#", dtype{}, 0, `{"a":[2]}`, true},
+		{`{"a":[1,2]}`, "a.-1", dtype{}, 0, `{"a":[1]}`, true},
+	}
+	for i, tt := range tests {
+		for _, optimistic := range []bool{false, true} {
+			opts := &Options{ReplaceInPlace: true, Optimistic: optimistic}
+			json := make([]byte, len(tt.json), len(tt.json)+tt.extra)
+			copy(json, tt.json)
+			res, err := SetBytesOptions(json, tt.path, tt.value, opts)
+			if err != nil {
+				t.Fatalf("test %d: %v", i, err)
+			}
+			if string(res) != tt.expect {
+				t.Fatalf("test %d: expected '%v', got '%s'", i, tt.expect, res)
+			}
+			if inplace := &res[0] == &json[0]; inplace != tt.inplace {
+				t.Fatalf("test %d: expected in place %v", i, tt.inplace)
+			}
+			if !tt.inplace && string(json) != tt.json {
+				t.Fatalf("test %d: json was modified '%s'", i, json)
+			}
+		}
+	}
+	json := []byte(`{"a":[1,2]}`)
+	res, err := DeleteBytesOptions(json, "a.5", &Options{ReplaceInPlace: true})
+	if err != nil || string(res) != `{"a":[1,2]}` {
+		t.Fatalf("unexpected result '%s', %v", res, err)
+	}
+}
+
+type marshalerValue struct{ n int }
+
+func (v marshalerValue) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"n":%d}`, v.n)), nil
+}
+
+type rawMarshaler string
+
+func (v rawMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(v), nil
+}
+
+func TestValueTypes(t *testing.T) {
+	str, n, f := "hi", 5, 1.5
+	i8, u16, f32 := int8(-8), uint16(16), float32(0.25)
+	var nilstr *string
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	tests := []struct {
+		value  interface{}
+		expect string
+	}{
+		{int(-7), `-7`},
+		{uint(7), `7`},
+		{jsongo.RawMessage(`{"a":[1,2]}`), `{"a":[1,2]}`},
+		{jsongo.RawMessage(nil), `null`},
+		{jsongo.Number("1e10"), `1e10`},
+		{gjson.Parse(`[true, false]`), `[true, false]`},
+		{gjson.Get(`{}`, "missing"), `null`},
+		{&str, `"hi"`},
+		{&n, `5`},
+		{&f, `1.5`},
+		{&i8, `-8`},
+		{&u16, `16`},
+		{&f32, `0.25`},
+		{(*int32)(nil), `null`},
+		{(*uint8)(nil), `null`},
+		{nilstr, `null`},
+		{tm, `"2020-01-02T03:04:05.0000006Z"`},
+		{marshalerValue{3}, `{"n":3}`},
+		{(*marshalerValue)(nil), `null`},
+		{(*time.Time)(nil), `null`},
+		{(*net.IP)(nil), `null`},
+		{rawMarshaler(" [1, 2]\n"), `[1, 2]`},
+		{net.ParseIP("10.0.0.1"), `"10.0.0.1"`},
+	}
+	for i, tt := range tests {
+		res, err := Set(`{"a":0}`, "a", tt.value)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if expect := `{"a":` + tt.expect + `}`; res != expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, expect, res)
+		}
+	}
+	_, err := Set(`{"a":0}`, "a", jsongo.Number("1x"))
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+	res, err := Set(`{}`, "a", rawMarshaler(`{oops`))
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got '%v', %v", res, err)
+	}
+}
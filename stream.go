@@ -0,0 +1,664 @@
+package sjson
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+)
+
+// streamFlushSize is the amount of pending output that is written at once.
+const streamFlushSize = 32 * 1024
+
+// streamOp is an operation for Stream with its path split into components.
+type streamOp struct {
+	path  string
+	paths []pathResult
+	op    setOp
+}
+
+// streamPaths splits a path for Stream into its components. It's the same
+// as parseSimplePath, except that a "#" component is allowed, which matches
+// every element of an array.
+func streamPaths(path string) ([]pathResult, bool) {
+	var paths []pathResult
+	for {
+		var r pathResult
+		if path == "#" || strings.HasPrefix(path, "#.") {
+			r = pathResult{part: "#", gpart: "#"}
+			if len(path) > 1 {
+				r.path = path[2:]
+				r.more = true
+			}
+		} else {
+			var simple bool
+			if r, simple = parsePath(path); !simple {
+				return nil, false
+			}
+		}
+		paths = append(paths, r)
+		if !r.more {
+			return paths, true
+		}
+		path = r.path
+	}
+}
+
+// isWild returns true if the path component is the "#" wildcard. An
+// escaped "\#" is a key.
+func isWild(p pathResult) bool {
+	return p.gpart == "#"
+}
+
+// streamer edits a json stream. Output is collected in out and written to w
+// when it grows large, unless a value is being held back.
+type streamer struct {
+	r      *bufio.Reader
+	w      io.Writer
+	out    []byte
+	hold   int // output is not written while hold is greater than zero
+	offset int // position of the next byte in the input
+	line   int
+	column int
+}
+
+func (s *streamer) syntaxError(msg string) error {
+	return &SyntaxError{Offset: s.offset, Line: s.line, Column: s.column,
+		Msg: msg}
+}
+
+// peek returns the next byte without reading it.
+func (s *streamer) peek() (byte, error) {
+	b, err := s.r.Peek(1)
+	if err != nil {
+		if err == io.EOF {
+			return 0, s.syntaxError("unexpected end of json")
+		}
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// next reads the next byte.
+func (s *streamer) next() (byte, error) {
+	c, err := s.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			return 0, s.syntaxError("unexpected end of json")
+		}
+		return 0, err
+	}
+	s.offset++
+	if c == '\n' {
+		s.line++
+		s.column = 1
+	} else {
+		s.column++
+	}
+	return c, nil
+}
+
+// put adds a byte to the output.
+func (s *streamer) put(c byte) error {
+	s.out = append(s.out, c)
+	if len(s.out) >= streamFlushSize && s.hold == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush writes the pending output.
+func (s *streamer) flush() error {
+	if len(s.out) == 0 {
+		return nil
+	}
+	_, err := s.w.Write(s.out)
+	s.out = s.out[:0]
+	return err
+}
+
+// space reads whitespace and appends it to buf. The end of the input is not
+// an error.
+func (s *streamer) space(buf []byte) ([]byte, error) {
+	for {
+		b, err := s.r.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return buf, nil
+			}
+			return buf, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\n', '\r':
+			s.next()
+			buf = append(buf, b[0])
+		default:
+			return buf, nil
+		}
+	}
+}
+
+// scan reads a single value, adding it to the output when keep is true.
+// Only strings and the nesting of containers are checked, the full syntax
+// of the value is not validated.
+func (s *streamer) scan(keep bool) error {
+	c, err := s.peek()
+	if err != nil {
+		return err
+	}
+	switch c {
+	case '"', '{', '[':
+	case 't', 'f', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return s.scanLiteral(keep)
+	default:
+		return s.syntaxError("unexpected " + quoteChar(c))
+	}
+	var depth int
+	for {
+		if c, err = s.next(); err != nil {
+			return err
+		}
+		if keep {
+			if err = s.put(c); err != nil {
+				return err
+			}
+		}
+		switch c {
+		case '"':
+			if err = s.scanString(keep); err != nil {
+				return err
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// scanString reads the rest of a string after the opening quote.
+func (s *streamer) scanString(keep bool) error {
+	for {
+		c, err := s.next()
+		if err != nil {
+			return err
+		}
+		if keep {
+			if err = s.put(c); err != nil {
+				return err
+			}
+		}
+		switch c {
+		case '"':
+			return nil
+		case '\\':
+			if c, err = s.next(); err != nil {
+				return err
+			}
+			if keep {
+				if err = s.put(c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// scanLiteral reads a number, true, false or null.
+func (s *streamer) scanLiteral(keep bool) error {
+	for {
+		b, err := s.r.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch b[0] {
+		case ' ', '\t', '\n', '\r', ',', ']', '}':
+			return nil
+		}
+		s.next()
+		if keep {
+			if err = s.put(b[0]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// capture reads a single value into memory.
+func (s *streamer) capture() ([]byte, error) {
+	mark := len(s.out)
+	s.hold++
+	err := s.scan(true)
+	s.hold--
+	v := append([]byte(nil), s.out[mark:]...)
+	s.out = s.out[:mark]
+	return v, err
+}
+
+// value edits the value at the current position. The ops are the
+// operations whose first d path components match the value. The prefix is
+// written before the value, unless the value is deleted.
+func (s *streamer) value(ops []*streamOp, d int, prefix []byte) (
+	deleted bool, err error) {
+	if len(ops) == 0 {
+		s.out = append(s.out, prefix...)
+		return false, s.scan(true)
+	}
+	last := -1
+	for i, o := range ops {
+		if len(o.paths) == d {
+			last = i
+		}
+	}
+	var v []byte
+	exists := true
+	if last != -1 {
+		// the value is replaced, so it's skipped rather than read
+		if err := s.scan(false); err != nil {
+			return false, err
+		}
+		v, exists, err = applyStream(nil, false, ops[last:], d)
+	} else {
+		var c byte
+		if c, err = s.peek(); err != nil {
+			return false, err
+		}
+		switch c {
+		case '{':
+			s.out = append(s.out, prefix...)
+			return false, s.object(ops, d)
+		case '[':
+			s.out = append(s.out, prefix...)
+			return false, s.array(ops, d)
+		}
+		if v, err = s.capture(); err != nil {
+			return false, err
+		}
+		v, exists, err = applyStream(v, true, ops, d)
+	}
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return true, nil
+	}
+	s.out = append(s.out, prefix...)
+	s.out = append(s.out, v...)
+	return false, nil
+}
+
+// applyStream applies the operations to a value in memory. The exists
+// argument is false when there is no value.
+func applyStream(v []byte, exists bool, ops []*streamOp, d int) ([]byte,
+	bool, error) {
+	for _, o := range ops {
+		if len(o.paths) == d {
+			if o.op.del {
+				v, exists = nil, false
+			} else {
+				v, exists = o.op.appendValue(nil), true
+			}
+			continue
+		}
+		if !exists && o.op.del {
+			continue
+		}
+		path := o.path
+		if d > 0 {
+			path = o.paths[d-1].path
+		}
+		var jstr string
+		if exists {
+			jstr = *(*string)(unsafe.Pointer(&v))
+		}
+		op := o.op
+		res, err := set(jstr, path, &op, nil)
+		if err == errNoChange {
+			continue
+		}
+		if err != nil {
+			return nil, false, streamError(err, o, d)
+		}
+		v, exists = res, true
+	}
+	return v, exists, nil
+}
+
+// streamError changes an error for a path relative to a value in the
+// stream into an error for the full path of the operation.
+func streamError(err error, o *streamOp, d int) error {
+	if perr, ok := err.(*PathError); ok {
+		nerr := *perr
+		nerr.Path = o.path
+		if nerr.Component >= 0 {
+			nerr.Component += d
+		}
+		nerr.Offset = -1
+		return &nerr
+	}
+	return err
+}
+
+// members reads the members of an object or the elements of an array,
+// calling fn for each one with the whitespace and separator in front of it.
+// The fn returns the prefix that was passed to value and whether the member
+// was deleted. Deleting a member removes the comma before it, or the comma
+// after it for the first member. The whitespace before the closing
+// character is returned.
+func (s *streamer) members(end byte, fn func(lead []byte) ([]byte, bool,
+	error)) ([]byte, int, error) {
+	lead, err := s.space(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	// carry is the whitespace after the opening character when all members
+	// so far have been deleted, which is the case when dropped is set.
+	var carry []byte
+	var dropped bool
+	var written int
+	for {
+		c, err := s.peek()
+		if err != nil {
+			return nil, 0, err
+		}
+		if c == end {
+			break
+		}
+		if written == 0 && dropped {
+			lead = carry
+		}
+		prefix, deleted, err := fn(lead)
+		if err != nil {
+			return nil, 0, err
+		}
+		if deleted {
+			if written == 0 {
+				carry, dropped = prefix, true
+			}
+		} else {
+			written++
+		}
+		if lead, err = s.space(nil); err != nil {
+			return nil, 0, err
+		}
+		if c, err = s.peek(); err != nil {
+			return nil, 0, err
+		}
+		if c == end {
+			break
+		}
+		if c != ',' {
+			return nil, 0, s.syntaxError("expected ',' or '" + string(end) +
+				"', found " + quoteChar(c))
+		}
+		s.next()
+		lead = append(lead, ',')
+		if lead, err = s.space(lead); err != nil {
+			return nil, 0, err
+		}
+	}
+	return lead, written, nil
+}
+
+// object edits an object that is matched by the first d path components of
+// the ops.
+func (s *streamer) object(ops []*streamOp, d int) error {
+	s.next()
+	s.out = append(s.out, '{')
+	matched := make([]bool, len(ops))
+	var mops []*streamOp
+	trail, written, err := s.members('}', func(lead []byte) ([]byte, bool,
+		error) {
+		c, err := s.peek()
+		if err != nil {
+			return nil, false, err
+		}
+		if c != '"' {
+			return nil, false, s.syntaxError("expected string key, found " +
+				quoteChar(c))
+		}
+		key, err := s.capture()
+		if err != nil {
+			return nil, false, err
+		}
+		prefix := append(append([]byte(nil), lead...), key...)
+		if prefix, err = s.space(prefix); err != nil {
+			return nil, false, err
+		}
+		if c, err = s.peek(); err != nil {
+			return nil, false, err
+		}
+		if c != ':' {
+			return nil, false, s.syntaxError("expected ':', found " +
+				quoteChar(c))
+		}
+		s.next()
+		if prefix, err = s.space(append(prefix, ':')); err != nil {
+			return nil, false, err
+		}
+		name := keyString(key)
+		mops = mops[:0]
+		for i, o := range ops {
+			if p := o.paths[d]; !isWild(p) && p.part == name {
+				mops = append(mops, o)
+				matched[i] = true
+			}
+		}
+		deleted, err := s.value(mops, d+1, prefix)
+		return prefix[:len(lead)], deleted, err
+	})
+	if err != nil {
+		return err
+	}
+	s.out = append(s.out, trail...)
+	// create the members that were not found
+	done := make([]bool, len(ops))
+	for i, o := range ops {
+		if matched[i] || done[i] || isWild(o.paths[d]) {
+			continue
+		}
+		part := o.paths[d].part
+		mops = mops[:0]
+		for j := i; j < len(ops); j++ {
+			if !matched[j] && !done[j] && !isWild(ops[j].paths[d]) &&
+				ops[j].paths[d].part == part {
+				mops = append(mops, ops[j])
+				done[j] = true
+			}
+		}
+		v, exists, err := applyStream(nil, false, mops, d+1)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if written > 0 {
+				s.out = append(s.out, ',')
+			}
+			s.out = appendStringify(s.out, part)
+			s.out = append(s.out, ':')
+			s.out = append(s.out, v...)
+			written++
+		}
+	}
+	s.next()
+	return s.put('}')
+}
+
+// keyString returns the unescaped key for a raw json string.
+func keyString(key []byte) string {
+	if bytes.IndexByte(key, '\\') == -1 {
+		return string(key[1 : len(key)-1])
+	}
+	return gjson.ParseBytes(key).Str
+}
+
+// array edits an array that is matched by the first d path components of
+// the ops.
+func (s *streamer) array(ops []*streamOp, d int) error {
+	var delLast bool
+	for _, o := range ops {
+		p := o.paths[d]
+		if _, ok := atoui(p); ok || isWild(p) {
+			continue
+		}
+		if !p.force && p.part == "-1" {
+			delLast = delLast || (o.op.del && len(o.paths) == d+1)
+			continue
+		}
+		if !o.op.del {
+			return &PathError{Path: o.path, Component: d, Key: p.part,
+				Offset: s.offset, Err: ErrNonNumericKey}
+		}
+	}
+	s.next()
+	s.out = append(s.out, '[')
+	var index int
+	var mops []*streamOp
+	trail, written, err := s.members(']', func(lead []byte) ([]byte, bool,
+		error) {
+		mops = mops[:0]
+		for _, o := range ops {
+			p := o.paths[d]
+			if n, ok := atoui(p); isWild(p) || (ok && n == index) {
+				mops = append(mops, o)
+			}
+		}
+		index++
+		if !delLast {
+			deleted, err := s.value(mops, d+1, lead)
+			return lead, deleted, err
+		}
+		// hold the element back until it's known whether it's the last
+		mark := len(s.out)
+		s.hold++
+		deleted, err := s.value(mops, d+1, lead)
+		if err == nil && !deleted {
+			var ws []byte
+			ws, err = s.space(nil)
+			if err == nil {
+				var c byte
+				c, err = s.peek()
+				if c == ']' {
+					s.out = s.out[:mark]
+					deleted = true
+				}
+				s.out = append(s.out, ws...)
+			}
+		}
+		s.hold--
+		return lead, deleted, err
+	})
+	if err != nil {
+		return err
+	}
+	s.out = append(s.out, trail...)
+	// append the elements after the end of the array
+	var tail []byte
+	for _, o := range ops {
+		p := o.paths[d]
+		path := p.part
+		if n, ok := atoui(p); ok && n >= index {
+			path = strconv.Itoa(n - index)
+		} else if p.force || p.part != "-1" || o.op.del ||
+			len(o.paths) != d+1 {
+			continue
+		}
+		if p.more {
+			path += "." + p.path
+		}
+		if tail == nil {
+			tail = []byte("[]")
+		}
+		op := o.op
+		res, err := set(*(*string)(unsafe.Pointer(&tail)), path, &op, nil)
+		if err == errNoChange {
+			continue
+		}
+		if err != nil {
+			return streamError(err, o, d)
+		}
+		tail = res
+	}
+	if len(tail) > 2 {
+		if written > 0 {
+			s.out = append(s.out, ',')
+		}
+		s.out = append(s.out, tail[1:len(tail)-1]...)
+	}
+	s.next()
+	return s.put(']')
+}
+
+// Stream reads json from r, applies the operations, and writes the result
+// to w. The document is never held in memory as a whole. Values that are
+// not affected by the operations are copied to w as they are read, and
+// values that are deleted or replaced are skipped without being read into
+// memory. This allows editing documents that are larger than the available
+// memory, such as an export that is a single array of records.
+//
+//	ops := []sjson.Op{
+//		{Kind: sjson.OpDelete, Path: "#.password"},
+//		{Kind: sjson.OpSet, Path: "#.version", Value: 2},
+//	}
+//	err := sjson.Stream(w, r, ops)
+//
+// The paths must be simple paths, except that a "#" component matches every
+// element of an array. Unlike SetMany, all paths refer to the input
+// document, so a deleted array element doesn't change the indexes of the
+// elements after it. The input is only checked for valid strings and
+// nesting. When an error is returned the output that was already written to
+// w is incomplete.
+func Stream(w io.Writer, r io.Reader, ops []Op) error {
+	sops := make([]*streamOp, len(ops))
+	for i, op := range ops {
+		if op.Path == "" {
+			return &PathError{Component: -1, Offset: -1, Err: ErrEmptyPath}
+		}
+		paths, ok := streamPaths(op.Path)
+		if !ok {
+			return &PathError{Path: op.Path, Component: -1, Offset: -1,
+				Err: ErrComplexPath}
+		}
+		sop, err := opValue(op)
+		if err != nil {
+			return err
+		}
+		sops[i] = &streamOp{path: op.Path, paths: paths, op: sop}
+	}
+	s := &streamer{r: bufio.NewReader(r), w: w, line: 1, column: 1}
+	var err error
+	if s.out, err = s.space(make([]byte, 0, streamFlushSize)); err != nil {
+		return err
+	}
+	if _, err := s.r.Peek(1); err == io.EOF {
+		// an empty document is created like it is by Set
+		v, _, err := applyStream(nil, false, sops, 0)
+		if err != nil {
+			return err
+		}
+		s.out = append(s.out, v...)
+		return s.flush()
+	}
+	if _, err := s.value(sops, 0, nil); err != nil {
+		return err
+	}
+	if s.out, err = s.space(s.out); err != nil {
+		return err
+	}
+	if b, err := s.r.Peek(1); err == nil {
+		return s.syntaxError("unexpected " + quoteChar(b[0]) + " after value")
+	} else if err != io.EOF {
+		return err
+	}
+	return s.flush()
+}
@@ -0,0 +1,180 @@
+package sjson
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestStream(t *testing.T) {
+	json := `{
+  "name": {"first": "Tom", "last": "Anderson"},
+  "age": 37,
+  "children": ["Sara", "Alex", "Jack"],
+  "fav.movie": "Deer Hunter"
+}`
+	tests := [][]Op{
+		{
+			{Kind: OpSet, Path: "name.last", Value: "Smith"},
+			{Kind: OpSet, Path: "age", Value: 38},
+			{Kind: OpSetRaw, Path: "children.1", Value: `{"a":1}`},
+			{Kind: OpDelete, Path: "fav\\.movie"},
+		},
+		{
+			{Kind: OpDelete, Path: "name.first"},
+			{Kind: OpDelete, Path: "name.last"},
+			{Kind: OpDelete, Path: "age"},
+		},
+		{
+			{Kind: OpSet, Path: "name", Value: "Tom"},
+			{Kind: OpSet, Path: "name.first", Value: "Tim"},
+		},
+		{
+			{Kind: OpSet, Path: "name.middle", Value: "J"},
+			{Kind: OpSet, Path: "new.path", Value: true},
+			{Kind: OpDelete, Path: "missing.path"},
+			{Kind: OpSet, Path: "children.-1", Value: "Ann"},
+		},
+		{
+			{Kind: OpDelete, Path: "children.-1"},
+			{Kind: OpSet, Path: "age.years", Value: 1},
+		},
+	}
+	for i, ops := range tests {
+		expect := setSequential(t, json, ops)
+		for _, one := range []bool{false, true} {
+			var r io.Reader = strings.NewReader(json)
+			if one {
+				r = iotest.OneByteReader(r)
+			}
+			var w bytes.Buffer
+			if err := Stream(&w, r, ops); err != nil {
+				t.Fatalf("test %d: %v", i, err)
+			}
+			if w.String() != expect {
+				t.Fatalf("test %d: expected '%v', got '%v'", i, expect,
+					w.String())
+			}
+		}
+	}
+}
+
+func TestStreamWildcard(t *testing.T) {
+	json := ` [{"id":1,"password":"x"}, {"id":2}, 3] `
+	ops := []Op{
+		{Kind: OpDelete, Path: "#.password"},
+		{Kind: OpSet, Path: "#.version", Value: 2},
+		{Kind: OpSet, Path: "-1", Value: map[string]int{"id": 4}},
+	}
+	expect := ` [{"id":1,"version":2}, {"id":2,"version":2}, ` +
+		`{"version":2},{"id":4}] `
+	var w bytes.Buffer
+	if err := Stream(&w, strings.NewReader(json), ops); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != expect {
+		t.Fatalf("expected '%v', got '%v'", expect, w.String())
+	}
+}
+
+func TestStreamCompact(t *testing.T) {
+	tests := []struct {
+		json   string
+		ops    []Op
+		expect string
+	}{
+		{`[1,2,3]`, []Op{{Kind: OpDelete, Path: "0"}}, `[2,3]`},
+		{`[1,2,3]`, []Op{{Kind: OpDelete, Path: "0"},
+			{Kind: OpDelete, Path: "1"}}, `[3]`},
+		{`{"a":[{"x":1},{"x":2}]}`, []Op{{Kind: OpDelete, Path: "a.0"}},
+			`{"a":[{"x":2}]}`},
+		{`{"a":1,"b":2}`, []Op{{Kind: OpDelete, Path: "a"}}, `{"b":2}`},
+		{`[ 1, 2]`, []Op{{Kind: OpDelete, Path: "0"}}, `[ 2]`},
+	}
+	for i, tt := range tests {
+		var w bytes.Buffer
+		if err := Stream(&w, strings.NewReader(tt.json), tt.ops); err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if w.String() != tt.expect {
+			t.Fatalf("test %d: expected '%v', got '%v'", i, tt.expect,
+				w.String())
+		}
+	}
+}
+
+func TestStreamLarge(t *testing.T) {
+	const n = 20000
+	var json bytes.Buffer
+	json.WriteString("[\n")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			json.WriteString(",\n")
+		}
+		json.WriteString(`  {"id":` + strconv.Itoa(i) +
+			`,"tags":["a","b"],"secret":"` + strings.Repeat("x", 64) + `"}`)
+	}
+	json.WriteString("\n]\n")
+	ops := []Op{
+		{Kind: OpDelete, Path: "#.secret"},
+		{Kind: OpSet, Path: "#.tags.-1", Value: "c"},
+		{Kind: OpDelete, Path: "0"},
+	}
+	var w bytes.Buffer
+	if err := Stream(&w, &json, ops); err != nil {
+		t.Fatal(err)
+	}
+	res := gjson.ParseBytes(w.Bytes())
+	if res.Get("#").Int() != n-1 {
+		t.Fatalf("expected %d records, got %d", n-1, res.Get("#").Int())
+	}
+	if res.Get("0.id").Int() != 1 || res.Get("#.secret|#").Int() != 0 ||
+		res.Get("99.tags").Raw != `["a","b","c"]` {
+		t.Fatalf("unexpected result '%s'", res.Get("99").Raw)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	var w bytes.Buffer
+	err := Stream(&w, strings.NewReader(`{}`), []Op{{Path: "a.#(b=1)"}})
+	if !errors.Is(err, ErrComplexPath) {
+		t.Fatalf("expected ErrComplexPath, got %v", err)
+	}
+	err = Stream(&w, strings.NewReader(`{}`), []Op{{Path: ""}})
+	if !errors.Is(err, ErrEmptyPath) {
+		t.Fatalf("expected ErrEmptyPath, got %v", err)
+	}
+	err = Stream(&w, strings.NewReader(`{"a":[1]}`),
+		[]Op{{Path: "a.b", Value: 1}})
+	if !errors.Is(err, ErrNonNumericKey) {
+		t.Fatalf("expected ErrNonNumericKey, got %v", err)
+	}
+	tests := []struct {
+		json         string
+		line, column int
+	}{
+		{"{\n  \"a\": 1\n  \"b\": 2}", 3, 3},
+		{`{"a":{"b":2]`, 1, 12},
+		{`{"a" 1}`, 1, 6},
+		{`{"a":1} x`, 1, 9},
+		{`{"a":[1,?]}`, 1, 9},
+	}
+	for i, tt := range tests {
+		err := Stream(&w, strings.NewReader(tt.json),
+			[]Op{{Path: "a.0", Value: 1}})
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Fatalf("test %d: expected SyntaxError, got %v", i, err)
+		}
+		if serr.Line != tt.line || serr.Column != tt.column {
+			t.Fatalf("test %d: expected line %d, column %d, got %v", i,
+				tt.line, tt.column, err)
+		}
+	}
+}
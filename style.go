@@ -0,0 +1,212 @@
+package sjson
+
+import "strings"
+
+// jsonStyle is the formatting of the members of a json container.
+type jsonStyle struct {
+	newline string // newline between members, empty for a single line
+	indent  string // indentation of the members
+	outdent string // indentation of the closing bracket
+	unit    string // one level of indentation
+	colon   string // separator between keys and values
+	comma   string // whitespace that follows commas on a single line
+}
+
+// child returns the style of a new container nested in st.
+func (st *jsonStyle) child() *jsonStyle {
+	if st == nil || st.newline == "" {
+		return st
+	}
+	child := *st
+	child.outdent = st.indent
+	child.indent = st.indent + st.unit
+	return &child
+}
+
+// appendColon appends the separator between a key and a value.
+func (st *jsonStyle) appendColon(buf []byte) []byte {
+	if st == nil || st.colon == "" {
+		return append(buf, ':')
+	}
+	return append(buf, st.colon...)
+}
+
+// appendOpen appends the whitespace before the first member.
+func (st *jsonStyle) appendOpen(buf []byte) []byte {
+	if st == nil || st.newline == "" {
+		return buf
+	}
+	buf = append(buf, st.newline...)
+	return append(buf, st.indent...)
+}
+
+// appendComma appends a comma and the whitespace before the next member.
+func (st *jsonStyle) appendComma(buf []byte) []byte {
+	buf = append(buf, ',')
+	if st == nil {
+		return buf
+	}
+	if st.newline == "" {
+		return append(buf, st.comma...)
+	}
+	buf = append(buf, st.newline...)
+	return append(buf, st.indent...)
+}
+
+// appendClose appends the whitespace before the closing bracket. The ws is
+// the original whitespace, which is kept when it is on a different line.
+func (st *jsonStyle) appendClose(buf []byte, ws string) []byte {
+	if st == nil || st.newline == "" || strings.IndexByte(ws, '\n') != -1 {
+		return append(buf, ws...)
+	}
+	buf = append(buf, st.newline...)
+	return append(buf, st.outdent...)
+}
+
+// lineIndent returns the indentation of the last line in buf.
+func lineIndent(buf []byte) string {
+	i := len(buf) - 1
+	for ; i >= 0; i-- {
+		if buf[i] == '\n' {
+			break
+		}
+	}
+	j := i + 1
+	for j < len(buf) && (buf[j] == ' ' || buf[j] == '\t') {
+		j++
+	}
+	return string(buf[i+1 : j])
+}
+
+// valueEnd returns the index just after the json value at i.
+func valueEnd(json string, i int) int {
+	if i >= len(json) {
+		return i
+	}
+	switch json[i] {
+	case '"':
+		for i++; i < len(json); i++ {
+			if json[i] == '\\' {
+				i++
+			} else if json[i] == '"' {
+				return i + 1
+			}
+		}
+		return i
+	case '{', '[':
+		var depth int
+		for ; i < len(json); i++ {
+			switch json[i] {
+			case '"':
+				i = valueEnd(json, i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	}
+	for ; i < len(json); i++ {
+		switch json[i] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			return i
+		}
+	}
+	return i
+}
+
+// detectStyle returns the style of the container at the start of json,
+// where buf holds the json that comes before the container. The last return
+// value is the index in json just after the last member, or just after the
+// opening bracket when the container is empty. Empty containers take on the
+// style of the parent container.
+func detectStyle(buf []byte, json string, parent jsonStyle) (st jsonStyle,
+	last int) {
+	st = parent
+	i := 0
+	for i < len(json) && json[i] <= ' ' {
+		i++
+	}
+	if i == len(json) || (json[i] != '{' && json[i] != '[') {
+		return parent, 0
+	}
+	obj := json[i] == '{'
+	i++
+	last = i
+	for i < len(json) && json[i] <= ' ' {
+		i++
+	}
+	line := lineIndent(buf)
+	st.outdent = line
+	if i == len(json) || json[i] == '}' || json[i] == ']' {
+		if st.newline != "" {
+			st.indent = line + st.unit
+		}
+		return st, last
+	}
+	ws := json[last:i]
+	if nl := strings.LastIndexByte(ws, '\n'); nl != -1 {
+		st.newline = "\n"
+		if nl > 0 && ws[nl-1] == '\r' {
+			st.newline = "\r\n"
+		}
+		st.indent = ws[nl+1:]
+	} else {
+		st.newline = ""
+		st.indent = ""
+	}
+	for first := true; i < len(json); first = false {
+		if obj {
+			if json[i] != '"' {
+				break
+			}
+			i = valueEnd(json, i)
+			j := i
+			for j < len(json) && json[j] <= ' ' {
+				j++
+			}
+			if j == len(json) || json[j] != ':' {
+				break
+			}
+			for j++; j < len(json) && json[j] <= ' '; j++ {
+			}
+			if first {
+				st.colon = json[i:j]
+			}
+			i = j
+		}
+		i = valueEnd(json, i)
+		last = i
+		for i < len(json) && json[i] <= ' ' {
+			i++
+		}
+		if i == len(json) || json[i] != ',' {
+			break
+		}
+		i++
+		j := i
+		for i < len(json) && json[i] <= ' ' {
+			i++
+		}
+		if first && st.newline == "" {
+			st.comma = json[j:i]
+		}
+	}
+	if st.newline != "" {
+		ws = json[last:i]
+		if nl := strings.LastIndexByte(ws, '\n'); nl != -1 {
+			st.outdent = ws[nl+1:]
+		}
+		if len(st.indent) > len(st.outdent) &&
+			strings.HasPrefix(st.indent, st.outdent) {
+			st.unit = st.indent[len(st.outdent):]
+		} else if st.unit == "" {
+			st.unit = "  "
+		}
+	}
+	return st, last
+}
@@ -0,0 +1,55 @@
+package sjson
+
+import "testing"
+
+func TestMatchIndent(t *testing.T) {
+	opts := &Options{MatchIndent: true}
+	tests := []struct {
+		json, path, value, expect string
+	}{
+		{"{\n  \"a\": 1\n}", "b", "2",
+			"{\n  \"a\": 1,\n  \"b\": 2\n}"},
+		{"{\n    \"a\": {\n        \"x\": 1\n    }\n}\n", "a.y.z", "2",
+			"{\n    \"a\": {\n        \"x\": 1,\n        \"y\": {\n" +
+				"            \"z\": 2\n        }\n    }\n}\n"},
+		{"{\r\n\t\"a\":[\r\n\t\t1\r\n\t]\r\n}", "a.-1", "2",
+			"{\r\n\t\"a\":[\r\n\t\t1,\r\n\t\t2\r\n\t]\r\n}"},
+		{"{\n  \"a\": [\n    1\n  ]\n}", "a.3", "2",
+			"{\n  \"a\": [\n    1,\n    null,\n    null,\n    2\n  ]\n}"},
+		{"{\n  \"a\": {}\n}", "a.b", "1",
+			"{\n  \"a\": {\n    \"b\": 1\n  }\n}"},
+		{"{\n  \"a\": []\n}", "a.1", "1",
+			"{\n  \"a\": [\n    null,\n    1\n  ]\n}"},
+		{`{"a": 1, "b": 2}`, "c.d", "3",
+			`{"a": 1, "b": 2, "c": {"d": 3}}`},
+		{`{"a":1}`, "b", "2", `{"a":1,"b":2}`},
+		{`[1, 2]`, "-1", "3", `[1, 2, 3]`},
+		{``, "a.b", "1", `{"a":{"b":1}}`},
+	}
+	for i, tt := range tests {
+		res, err := SetRawOptions(tt.json, tt.path, tt.value, opts)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res != tt.expect {
+			t.Fatalf("test %d: expected %q, got %q", i, tt.expect, res)
+		}
+	}
+	json := "{\n  \"a\": [\n    1,\n    3\n  ]\n}"
+	res, err := InsertRawOptions(json, "a.1", "2", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "{\n  \"a\": [\n    1,\n    2,\n    3\n  ]\n}"
+	if res != expect {
+		t.Fatalf("expected %q, got %q", expect, res)
+	}
+	bres, err := InsertBytesOptions([]byte(json), "a.0", 0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "{\n  \"a\": [\n    0,\n    1,\n    3\n  ]\n}"
+	if string(bres) != expect {
+		t.Fatalf("expected %q, got %q", expect, bres)
+	}
+}
@@ -0,0 +1,239 @@
+package sjson
+
+import "strconv"
+
+// SyntaxError is returned in Strict mode when the json document, or a raw
+// value that is set, is not valid json.
+type SyntaxError struct {
+	Value  bool   // the error is in the value rather than the document
+	Offset int    // byte offset of the error
+	Line   int    // line of the error, starting at 1
+	Column int    // column of the error in bytes, starting at 1
+	Msg    string // description of the error
+}
+
+func (err *SyntaxError) Error() string {
+	what := "invalid json"
+	if err.Value {
+		what = "invalid json value"
+	}
+	return what + ": " + err.Msg + " at line " + strconv.Itoa(err.Line) +
+		", column " + strconv.Itoa(err.Column)
+}
+
+// Unwrap returns ErrInvalidJSON.
+func (err *SyntaxError) Unwrap() error {
+	return ErrInvalidJSON
+}
+
+// validate returns a *SyntaxError if json is not a single valid json value.
+func validate(json string, value bool) error {
+	i := skipSpace(json, 0)
+	var msg string
+	if i == len(json) {
+		msg = "unexpected end of json"
+	} else {
+		i, msg = validValue(json, i)
+		if msg == "" {
+			i = skipSpace(json, i)
+			if i < len(json) {
+				msg = "unexpected " + quoteChar(json[i]) + " after value"
+			}
+		}
+	}
+	if msg == "" {
+		return nil
+	}
+	err := &SyntaxError{Value: value, Offset: i, Line: 1, Column: 1, Msg: msg}
+	for j := 0; j < i; j++ {
+		if json[j] == '\n' {
+			err.Line++
+			err.Column = 1
+		} else {
+			err.Column++
+		}
+	}
+	return err
+}
+
+func skipSpace(json string, i int) int {
+	for ; i < len(json); i++ {
+		switch json[i] {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func quoteChar(c byte) string {
+	return "character " + strconv.QuoteRune(rune(c))
+}
+
+// validValue validates the value at i, returning the index after the value,
+// or the index of the error and its description.
+func validValue(json string, i int) (int, string) {
+	if i == len(json) {
+		return i, "unexpected end of json"
+	}
+	switch c := json[i]; c {
+	case '{', '[':
+		return validContainer(json, i)
+	case '"':
+		return validString(json, i)
+	case 't':
+		return validLiteral(json, i, "true")
+	case 'f':
+		return validLiteral(json, i, "false")
+	case 'n':
+		return validLiteral(json, i, "null")
+	default:
+		if c == '-' || (c >= '0' && c <= '9') {
+			return validNumber(json, i)
+		}
+		return i, "unexpected " + quoteChar(c)
+	}
+}
+
+func validContainer(json string, i int) (int, string) {
+	obj := json[i] == '{'
+	end := byte(']')
+	if obj {
+		end = '}'
+	}
+	i = skipSpace(json, i+1)
+	if i < len(json) && json[i] == end {
+		return i + 1, ""
+	}
+	for {
+		var msg string
+		if obj {
+			if i == len(json) {
+				return i, "unexpected end of json"
+			}
+			if json[i] != '"' {
+				return i, "expected string key, found " + quoteChar(json[i])
+			}
+			if i, msg = validString(json, i); msg != "" {
+				return i, msg
+			}
+			i = skipSpace(json, i)
+			if i == len(json) {
+				return i, "unexpected end of json"
+			}
+			if json[i] != ':' {
+				return i, "expected ':', found " + quoteChar(json[i])
+			}
+			i = skipSpace(json, i+1)
+		}
+		if i, msg = validValue(json, i); msg != "" {
+			return i, msg
+		}
+		i = skipSpace(json, i)
+		if i == len(json) {
+			return i, "unexpected end of json"
+		}
+		if json[i] == end {
+			return i + 1, ""
+		}
+		if json[i] != ',' {
+			return i, "expected ',' or '" + string(end) + "', found " +
+				quoteChar(json[i])
+		}
+		i = skipSpace(json, i+1)
+	}
+}
+
+func validString(json string, i int) (int, string) {
+	for i++; i < len(json); i++ {
+		switch c := json[i]; {
+		case c == '"':
+			return i + 1, ""
+		case c < ' ':
+			return i, "invalid control " + quoteChar(c) + " in string"
+		case c == '\\':
+			i++
+			if i == len(json) {
+				return i, "unexpected end of json"
+			}
+			switch json[i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				for j := 0; j < 4; j++ {
+					i++
+					if i == len(json) {
+						return i, "unexpected end of json"
+					}
+					if !isHex(json[i]) {
+						return i, "invalid " + quoteChar(json[i]) +
+							" in \\u escape"
+					}
+				}
+			default:
+				return i, "invalid escape " + quoteChar(json[i]) +
+					" in string"
+			}
+		}
+	}
+	return i, "unexpected end of json"
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') ||
+		(c >= 'A' && c <= 'F')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func validNumber(json string, i int) (int, string) {
+	if json[i] == '-' {
+		i++
+	}
+	if i == len(json) || !isDigit(json[i]) {
+		return i, "invalid number"
+	}
+	if json[i] == '0' {
+		i++
+	} else {
+		for i < len(json) && isDigit(json[i]) {
+			i++
+		}
+	}
+	if i < len(json) && json[i] == '.' {
+		i++
+		if i == len(json) || !isDigit(json[i]) {
+			return i, "invalid number"
+		}
+		for i < len(json) && isDigit(json[i]) {
+			i++
+		}
+	}
+	if i < len(json) && (json[i] == 'e' || json[i] == 'E') {
+		i++
+		if i < len(json) && (json[i] == '+' || json[i] == '-') {
+			i++
+		}
+		if i == len(json) || !isDigit(json[i]) {
+			return i, "invalid number"
+		}
+		for i < len(json) && isDigit(json[i]) {
+			i++
+		}
+	}
+	return i, ""
+}
+
+func validLiteral(json string, i int, lit string) (int, string) {
+	for j := 0; j < len(lit); j++ {
+		if i+j == len(json) {
+			return i + j, "unexpected end of json"
+		}
+		if json[i+j] != lit[j] {
+			return i + j, "unexpected " + quoteChar(json[i+j])
+		}
+	}
+	return i + len(lit), ""
+}
@@ -0,0 +1,89 @@
+package sjson
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		json   string
+		offset int
+	}{
+		{`{"a":[1,2.5e10,-0,true,false,null,"é\n"]}`, -1},
+		{` [ ] `, -1},
+		{`{"a":1,}`, 7},
+		{`{"a" 1}`, 5},
+		{`{a:1}`, 1},
+		{`[1 2]`, 3},
+		{`[01]`, 2},
+		{`[1.]`, 3},
+		{`[-]`, 2},
+		{`[1e]`, 3},
+		{`"abc`, 4},
+		{"\"a\tb\"", 2},
+		{`"\x"`, 2},
+		{`"\u12g4"`, 5},
+		{`tru`, 3},
+		{`nul1`, 3},
+		{`{"a":1}}`, 7},
+		{``, 0},
+		{`[`, 1},
+	}
+	for _, tt := range tests {
+		err := validate(tt.json, false)
+		if tt.offset == -1 {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", tt.json, err)
+			}
+			continue
+		}
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("%s: expected a *SyntaxError, got %v", tt.json, err)
+		}
+		if serr.Offset != tt.offset {
+			t.Fatalf("%s: expected offset %d, got %d (%v)",
+				tt.json, tt.offset, serr.Offset, err)
+		}
+		if gjson.Valid(tt.json) {
+			t.Fatalf("%s: gjson says the json is valid", tt.json)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	opts := &Options{Strict: true}
+	_, err := SetRawOptions(`{"a":1}`, "b", `{"c":}`, opts)
+	var serr *SyntaxError
+	if !errors.As(err, &serr) || !serr.Value || serr.Offset != 5 {
+		t.Fatalf("expected a value error, got %v", err)
+	}
+	_, err = SetOptions("{\n  \"a\": 1\n  \"b\": 2\n}", "c", 3, opts)
+	if !errors.As(err, &serr) || serr.Value || serr.Line != 3 ||
+		serr.Column != 3 {
+		t.Fatalf("expected a document error, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("expected ErrInvalidJSON, got %v", err)
+	}
+	if err.Error() != "invalid json: expected ',' or '}', "+
+		"found character '\"' at line 3, column 3" {
+		t.Fatalf("unexpected message '%v'", err)
+	}
+	json, err := SetOptions("", "a", "b", opts)
+	if err != nil || json != `{"a":"b"}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	json, err = DeleteOptions(`{"a":1,"b":2}`, "a", opts)
+	if err != nil || json != `{"b":2}` {
+		t.Fatalf("unexpected result '%v', %v", json, err)
+	}
+	// invalid values go through when not strict
+	json, _ = SetRaw(`{"a":1}`, "a", `{`)
+	if json != `{"a":{}` {
+		t.Fatalf("unexpected result '%v'", json)
+	}
+}